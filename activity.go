@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxActivity is the number of watcher events kept in the activity log
+const maxActivity = 1000
+
+type activity struct {
+	time time.Time
+	op   string
+	path string
+	// start is the commit the numstat is measured against, which is loaded
+	// in the background
+	start  string
+	loaded bool
+	// failed is true if git failed to load the numstat
+	failed bool
+	adds   int
+	dels   int
+}
+
+// activityStatsMessage carries the numstats of the paths of activity entries
+// measured against start, or the error loading them
+type activityStatsMessage struct {
+	start string
+	paths []string
+	stats map[string]stat
+	err   error
+}
+
+type activityModel struct {
+	listModel
+	entries []activity
+	// loading is true while numstats are being loaded
+	loading bool
+}
+
+func newActivityModel() activityModel {
+	m := activityModel{}
+	m.listModel.init(0, false)
+	return m
+}
+
+func (m activityModel) name() string {
+	return "activity"
+}
+
+func (m activityModel) selected() activity {
+	return m.entries[m.cursor]
+}
+
+// record adds a watcher event to the log. Newer entries are listed first.
+// The numstat of the changed file relative to start is loaded by loadStats.
+func (m *activityModel) record(op, path, start string) {
+	a := activity{
+		time:  time.Now(),
+		op:    op,
+		path:  path,
+		start: start,
+	}
+
+	m.entries = append([]activity{a}, m.entries...)
+	if len(m.entries) > maxActivity {
+		m.entries = m.entries[:maxActivity]
+	}
+
+	if m.cursor > 0 {
+		m.cursor++
+	}
+	m.listModel.setCount(len(m.entries))
+	if m.cursor < 0 {
+		m.setCursor(0)
+	}
+}
+
+// loadStats returns a command that loads the numstats of the entries that
+// don't have one yet, in one git diff per batch. Only one batch is loaded at
+// a time, and events that arrive meanwhile are left for the next one.
+func (m *activityModel) loadStats() tea.Cmd {
+	if m.loading {
+		return nil
+	}
+
+	start := ""
+	var paths []string
+	seen := map[string]bool{}
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		a := m.entries[i]
+		if a.loaded || (start != "" && a.start != start) {
+			continue
+		}
+		start = a.start
+		if !seen[a.path] {
+			seen[a.path] = true
+			paths = append(paths, a.path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	m.loading = true
+	return func() tea.Msg {
+		stats, err := gitFileStats(start, paths)
+		return activityStatsMessage{start, paths, stats, err}
+	}
+}
+
+// setStats fills in the numstats of a loaded batch, and returns a command to
+// load the next one. The entries of a batch that failed are marked so they
+// aren't loaded again.
+func (m *activityModel) setStats(msg activityStatsMessage) tea.Cmd {
+	m.loading = false
+	inBatch := map[string]bool{}
+	for _, path := range msg.paths {
+		inBatch[path] = true
+	}
+	for i, a := range m.entries {
+		if a.loaded || a.start != msg.start || !inBatch[a.path] {
+			continue
+		}
+		s := msg.stats[a.path]
+		m.entries[i].adds = s.Adds
+		m.entries[i].dels = s.Dels
+		m.entries[i].loaded = true
+		m.entries[i].failed = msg.err != nil
+	}
+	return m.loadStats()
+}
+
+func (m activityModel) renderActivity(index int) string {
+	a := m.entries[index]
+
+	if index == m.cursor {
+		activityTimeStyle.Background(cursorBg)
+		activityOpStyle.Background(cursorBg)
+		statAddStyle.Background(cursorBg)
+		statDelStyle.Background(cursorBg)
+		statStyle.Background(cursorBg)
+	} else {
+		activityTimeStyle.UnsetBackground()
+		activityOpStyle.UnsetBackground()
+		statAddStyle.UnsetBackground()
+		statDelStyle.UnsetBackground()
+		statStyle.UnsetBackground()
+	}
+
+	statAddStyle.Width(6).PaddingRight(1)
+	statDelStyle.Width(6).PaddingRight(1)
+	statStyle.Width(m.width)
+
+	adds, dels := "+?", "-?"
+	if a.loaded && !a.failed {
+		adds, dels = fmt.Sprintf("+%d", a.adds), fmt.Sprintf("-%d", a.dels)
+	}
+
+	return lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		activityTimeStyle.Render(a.time.Format("15:04:05")),
+		activityOpStyle.Render(a.op),
+		statAddStyle.Render(adds),
+		statDelStyle.Render(dels),
+		statStyle.Render(a.path),
+	)
+}
+
+func (m activityModel) render() string {
+	var lines []string
+	if m.end-m.start == 0 {
+		for i := 0; i < m.height/4; i++ {
+			lines = append(lines, "")
+		}
+		centerStyle := lipgloss.NewStyle().
+			Align(lipgloss.Center).
+			Width(m.width)
+		lines = append(lines, centerStyle.Render("No activity"))
+		return lipgloss.JoinVertical(lipgloss.Center, lines...)
+	} else {
+		for i := m.start; i < m.end; i++ {
+			lines = append(lines, m.renderActivity(i))
+		}
+		return lipgloss.JoinVertical(lipgloss.Left, lines...)
+	}
+}
+
+func (m *activityModel) findNext(re *regexp.Regexp) {
+	for i := m.cursor + 1; i < m.count; i++ {
+		if e := m.entries[i]; re.MatchString(e.path) || re.MatchString(e.op) {
+			m.setCursor(i)
+			break
+		}
+	}
+}
+
+func (m *activityModel) findPrev(re *regexp.Regexp) {
+	for i := m.cursor - 1; i >= 0; i-- {
+		if e := m.entries[i]; re.MatchString(e.path) || re.MatchString(e.op) {
+			m.setCursor(i)
+			break
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const nullHash = "0000000000000000000000000000000000000000"
+
+// blobInfo describes one version of a binary file
+type blobInfo struct {
+	exists bool
+	hash   string
+	data   []byte
+	mime   string
+	width  int
+	height int
+}
+
+// readBlob reads a version of a file. A null hash refers to the worktree
+// version of the file if it wasn't deleted.
+func readBlob(hash, path string, deleted bool) (b blobInfo) {
+	if hash == "" || (hash == nullHash && deleted) {
+		return
+	}
+
+	if hash == nullHash {
+		data, err := os.ReadFile(filepath.Join(getGitTopLevel(), path))
+		if err != nil {
+			return
+		}
+		b.data = data
+		b.hash = "worktree"
+	} else {
+		b.data = gitCatFile(hash)
+		b.hash = trunc(hash, 8)
+	}
+
+	b.exists = true
+	b.mime = http.DetectContentType(b.data)
+	if config, _, err := image.DecodeConfig(bytes.NewReader(b.data)); err == nil {
+		b.width = config.Width
+		b.height = config.Height
+	}
+
+	return
+}
+
+func formatSize(size int) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s (%d bytes)", value, units[unit], size)
+}
+
+func (b blobInfo) describe() string {
+	if !b.exists {
+		return "(none)"
+	}
+
+	parts := []string{formatSize(len(b.data)), b.hash, b.mime}
+	if b.width > 0 {
+		parts = append(parts, fmt.Sprintf("%d×%d", b.width, b.height))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// describeBinary returns the lines shown in the diff view for a binary file
+func describeBinary(s stat) []string {
+	oldBlob := readBlob(s.OldHash, s.OldPath, true)
+	newBlob := readBlob(s.NewHash, s.Path, s.Status == "D")
+
+	lines := []string{fmt.Sprintf("Binary file %s", s.Path), ""}
+	if s.OldPath != "" {
+		lines[0] += " ← " + s.OldPath
+	}
+	if oldBlob.exists {
+		lines = append(lines, "- old: "+oldBlob.describe())
+	}
+	if newBlob.exists {
+		lines = append(lines, "+ new: "+newBlob.describe())
+	}
+	if oldBlob.exists && newBlob.exists {
+		lines = append(lines, fmt.Sprintf(
+			"  size change: %+d bytes",
+			len(newBlob.data)-len(oldBlob.data),
+		))
+	}
+	lines = append(lines, "", "Press e to extract both versions to temporary files")
+
+	return lines
+}
+
+// extractBinary writes both versions of a file to temporary files and returns
+// their paths
+func extractBinary(s stat) (paths []string, err error) {
+	oldPath := s.OldPath
+	if oldPath == "" {
+		oldPath = s.Path
+	}
+
+	versions := []struct {
+		label string
+		blob  blobInfo
+		path  string
+	}{
+		{"old", readBlob(s.OldHash, oldPath, true), oldPath},
+		{"new", readBlob(s.NewHash, s.Path, s.Status == "D"), s.Path},
+	}
+
+	for _, v := range versions {
+		if !v.blob.exists {
+			continue
+		}
+
+		ext := filepath.Ext(v.path)
+		base := strings.TrimSuffix(filepath.Base(v.path), ext)
+		f, err := os.CreateTemp("", fmt.Sprintf("%s-%s-*%s", base, v.label, ext))
+		if err != nil {
+			return paths, err
+		}
+		_, err = f.Write(v.blob.data)
+		f.Close()
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, f.Name())
+	}
+
+	return
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// the number of lines of context saved on each side of a commented line
+const commentContext = 2
+
+// comment is a review note attached to a line of a diff. The line is found
+// again after the diff changes by matching its text and context.
+type comment struct {
+	Range  string
+	Path   string
+	Side   string
+	Line   int
+	Text   string
+	Before []string
+	After  []string
+	Body   string
+	Time   time.Time
+}
+
+type commentStore struct {
+	Comments []comment
+}
+
+func getCommentFile() string {
+	return filepath.Join(getGitDir(), "de", "comments.json")
+}
+
+func loadComments() *commentStore {
+	s := &commentStore{}
+	if data, err := os.ReadFile(getCommentFile()); err == nil {
+		json.Unmarshal(data, s)
+	}
+	return s
+}
+
+func (s *commentStore) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file := getCommentFile()
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, data, 0644)
+}
+
+func (s *commentStore) add(c comment) error {
+	s.Comments = append(s.Comments, c)
+	return s.save()
+}
+
+func (s *commentStore) remove(c comment) error {
+	for i, other := range s.Comments {
+		if other.Time.Equal(c.Time) && other.Path == c.Path && other.Body == c.Body {
+			s.Comments = append(s.Comments[:i], s.Comments[i+1:]...)
+			break
+		}
+	}
+	return s.save()
+}
+
+// forRange returns the comments in a commit range, ordered by path and line
+func (s *commentStore) forRange(r commitRange) []comment {
+	var comments []comment
+	for _, c := range s.Comments {
+		if c.Range == rangeKey(r) {
+			comments = append(comments, c)
+		}
+	}
+	sort.SliceStable(comments, func(i, j int) bool {
+		if comments[i].Path != comments[j].Path {
+			return comments[i].Path < comments[j].Path
+		}
+		return comments[i].Line < comments[j].Line
+	})
+	return comments
+}
+
+// forFile returns the comments on a file in a commit range
+func (s *commentStore) forFile(r commitRange, path string) []comment {
+	var comments []comment
+	for _, c := range s.forRange(r) {
+		if c.Path == path {
+			comments = append(comments, c)
+		}
+	}
+	return comments
+}
+
+// newComment anchors a comment to a line of a diff. It returns false if the
+// line isn't part of a hunk.
+func newComment(r commitRange, path string, diff []string, index int, body string) (c comment, ok bool) {
+	nums := numberDiffLines(diff)
+	line := diff[index]
+	if nums[index].old == 0 && nums[index].new == 0 {
+		return c, false
+	}
+
+	c = comment{
+		Range: rangeKey(r),
+		Path:  path,
+		Side:  "new",
+		Line:  nums[index].new,
+		Text:  line,
+		Body:  body,
+		Time:  time.Now(),
+	}
+	if strings.HasPrefix(line, "-") {
+		c.Side = "old"
+		c.Line = nums[index].old
+	}
+
+	for i := index - 1; i >= 0 && i >= index-commentContext; i-- {
+		if strings.HasPrefix(diff[i], "@@") {
+			break
+		}
+		c.Before = append([]string{diff[i]}, c.Before...)
+	}
+	for i := index + 1; i < len(diff) && i <= index+commentContext; i++ {
+		if strings.HasPrefix(diff[i], "@@") {
+			break
+		}
+		c.After = append(c.After, diff[i])
+	}
+
+	return c, true
+}
+
+// resolveComment finds the line a comment is attached to in a diff. Lines
+// with the same text are scored by how much of the saved context matches, and
+// then by how close they are to the saved line number. It returns -1 if the
+// line is no longer in the diff.
+func resolveComment(c comment, diff []string, nums []lineNumbers) int {
+	best := -1
+	bestScore := -1
+	bestDistance := 0
+
+	for i, line := range diff {
+		if line != c.Text {
+			continue
+		}
+
+		score := 0
+		for j, before := range c.Before {
+			k := i - len(c.Before) + j
+			if k >= 0 && diff[k] == before {
+				score++
+			}
+		}
+		for j, after := range c.After {
+			k := i + 1 + j
+			if k < len(diff) && diff[k] == after {
+				score++
+			}
+		}
+
+		lineNum := nums[i].new
+		if c.Side == "old" {
+			lineNum = nums[i].old
+		}
+		distance := lineNum - c.Line
+		if distance < 0 {
+			distance = -distance
+		}
+
+		if score > bestScore || (score == bestScore && distance < bestDistance) {
+			best = i
+			bestScore = score
+			bestDistance = distance
+		}
+	}
+
+	return best
+}
+
+// exportComments writes a markdown review summary of the comments in a commit
+// range and returns the path of the file
+func exportComments(comments []comment, title string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Review of %s\n", title)
+
+	path := ""
+	for _, c := range comments {
+		if c.Path != path {
+			path = c.Path
+			fmt.Fprintf(&b, "\n## %s\n", path)
+		}
+
+		fmt.Fprintf(&b, "\n**Line %d** (%s):\n\n```diff\n", c.Line, c.Side)
+		for _, line := range c.Before {
+			fmt.Fprintln(&b, line)
+		}
+		fmt.Fprintln(&b, c.Text)
+		for _, line := range c.After {
+			fmt.Fprintln(&b, line)
+		}
+		fmt.Fprintf(&b, "```\n\n%s\n", c.Body)
+	}
+
+	file := filepath.Join(getGitDir(), "de", "review.md")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(file, []byte(b.String()), 0644); err != nil {
+		return "", err
+	}
+
+	return filepath.Abs(file)
+}
+
+// commentsModel lists the comments in the current commit range
+type commentsModel struct {
+	listModel
+	comments []comment
+}
+
+func newCommentsModel() commentsModel {
+	m := commentsModel{}
+	m.listModel.init(0, false)
+	return m
+}
+
+func (m commentsModel) name() string {
+	return "comments"
+}
+
+func (m commentsModel) selected() comment {
+	return m.comments[m.cursor]
+}
+
+func (m *commentsModel) setComments(comments []comment) {
+	m.comments = comments
+	cursor := m.cursor
+	m.listModel.init(len(comments), false)
+	if cursor > 0 {
+		m.setCursor(min(cursor, m.count-1))
+	}
+}
+
+func (m commentsModel) renderComment(index int) string {
+	c := m.comments[index]
+
+	if index == m.cursor {
+		commentLocationStyle.Background(cursorBg)
+		statStyle.Background(cursorBg)
+	} else {
+		commentLocationStyle.UnsetBackground()
+		statStyle.UnsetBackground()
+	}
+
+	location := fmt.Sprintf("%s:%d", c.Path, c.Line)
+	commentLocationStyle.Width(min(lipgloss.Width(location)+2, m.width/2))
+	statStyle.Width(max(m.width-commentLocationStyle.GetWidth(), 0))
+
+	return lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		commentLocationStyle.Render(location),
+		statStyle.Render(c.Body),
+	)
+}
+
+func (m commentsModel) render() string {
+	var lines []string
+	if m.end-m.start == 0 {
+		for i := 0; i < m.height/4; i++ {
+			lines = append(lines, "")
+		}
+		centerStyle := lipgloss.NewStyle().
+			Align(lipgloss.Center).
+			Width(m.width)
+		lines = append(lines, centerStyle.Render("No comments"))
+		return lipgloss.JoinVertical(lipgloss.Center, lines...)
+	} else {
+		for i := m.start; i < m.end; i++ {
+			lines = append(lines, m.renderComment(i))
+		}
+		return lipgloss.JoinVertical(lipgloss.Left, lines...)
+	}
+}
+
+func (m *commentsModel) findNext(re *regexp.Regexp) {
+	for i := m.cursor + 1; i < m.count; i++ {
+		if re.MatchString(m.comments[i].Body) {
+			m.setCursor(i)
+			break
+		}
+	}
+}
+
+func (m *commentsModel) findPrev(re *regexp.Regexp) {
+	for i := m.cursor - 1; i >= 0; i-- {
+		if re.MatchString(m.comments[i].Body) {
+			m.setCursor(i)
+			break
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
@@ -37,6 +38,16 @@ func (m commitsModel) selected() commit {
 	return m.commits[m.cursor]
 }
 
+// rowOf returns the row index of a commit, or -1 if it's not in the list
+func (m commitsModel) rowOf(hash string) int {
+	for i, c := range m.commits {
+		if c.Commit == hash {
+			return i
+		}
+	}
+	return -1
+}
+
 func (m commitsModel) renderCommit(index int) string {
 	c := m.commit(index)
 
@@ -135,13 +146,13 @@ func (m commitsModel) renderCommit(index int) string {
 	return lipgloss.JoinHorizontal(
 		lipgloss.Top,
 		markerStyle.Render(marker),
-		hashStyle.Render(c.Commit[0:8]),
+		renderMatches(c.Commit[0:8], m.query, hashStyle),
 		ageStyle.Render(age),
-		nameStyle.Render(name),
+		renderMatches(name, m.query, nameStyle),
 		branches,
 		tags,
 		refs,
-		subjectStyle.Render(c.Subject),
+		renderMatches(c.Subject, m.query, subjectStyle),
 	)
 }
 
@@ -177,24 +188,36 @@ func (m commitsModel) getRangeStr() string {
 	return fmt.Sprintf("%s..%s", trunc(r.start, 8), trunc(r.end, 8))
 }
 
-func (m *commitsModel) findNext(query string) {
-	q := strings.ToLower(query)
-	for i := m.cursor + 1; i < m.count; i++ {
-		c := strings.ToLower(m.renderCommit(i))
-		if strings.Contains(c, q) {
-			m.setCursor(i)
-			break
+// matches returns the indexes of the commits whose hash, author, subject or
+// refs match re
+func (m commitsModel) matches(re *regexp.Regexp) []int {
+	var matches []int
+	for i, c := range m.commits {
+		if re.MatchString(c.Commit) || re.MatchString(c.AuthorName) ||
+			re.MatchString(c.Subject) || re.MatchString(c.Decoration) {
+			matches = append(matches, i)
 		}
 	}
+	return matches
 }
 
-func (m *commitsModel) findPrev(query string) {
-	q := strings.ToLower(query)
-	for i := m.cursor - 1; i >= 0; i-- {
-		c := strings.ToLower(m.renderCommit(i))
-		if strings.Contains(c, q) {
-			m.setCursor(i)
-			break
-		}
+// setQuery sets the search whose matches are highlighted and counted
+func (m *commitsModel) setQuery(re *regexp.Regexp) {
+	m.query = re
+	m.found = nil
+	if re != nil {
+		m.found = m.matches(re)
+	}
+}
+
+func (m *commitsModel) findNext(re *regexp.Regexp) {
+	if i := nextMatch(m.matches(re), m.cursor); i >= 0 {
+		m.setCursor(i)
+	}
+}
+
+func (m *commitsModel) findPrev(re *regexp.Regexp) {
+	if i := prevMatch(m.matches(re), m.cursor); i >= 0 {
+		m.setCursor(i)
 	}
-}
\ No newline at end of file
+}
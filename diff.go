@@ -1,22 +1,94 @@
 package main
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 )
 
 type diffModel struct {
 	listModel
-	diff        []string
-	commits		commitRange
-	path        string
-	oldPath     string
-	opts        diffOptions
+	diff    []string
+	commits commitRange
+	path    string
+	oldPath string
+	opts    diffOptions
+	// stat is the stat of the file being shown, which is used to describe
+	// binary files
+	stat stat
+
+	// lines that are new or changed since the previous live refresh
+	changes    map[int]bool
+	changedAt  time.Time
+	changeFade time.Duration
+
+	// rows are the screen rows of the view: every diff line, followed by
+	// any comments attached to it
+	rows     []diffRow
+	comments *commentStore
+	// fileComments are the comments on the current file
+	fileComments []comment
+	// line numbers of each diff line, which are shown in a gutter when
+	// showLineNums is true
+	lineNums     []lineNumbers
+	maxLineNum   int
+	showLineNums bool
+	// commenting is true while a comment is being written for the line at
+	// the top of the view
+	commenting bool
+
+	// moves are the moved blocks in the range, detected with the options in
+	// movesKey, and moved are the moved lines of the current file. Moves are
+	// detected in the background with the options in movesLoading.
+	moves        *moveMap
+	movesKey     string
+	movesLoading string
+	moved        map[int]movedLine
+
+	// highlights are the syntax highlighted contents of the diff lines, or
+	// nil if the file's language isn't known
+	highlights [][]span
+
+	// whitespace rules and tab width configured for the current file
+	wsRules  whitespaceRules
+	tabWidth int
+
+	// hscroll is the number of columns scrolled to the right, which is
+	// ignored when long lines are wrapped
+	hscroll int
+	wrap    bool
+
+	// folded marks the hunks that are folded to their headers, by hunk
+	// index until the diff is reloaded, and folds holds the number of lines
+	// each folded hunk hides, by the line of its header
+	folded map[int]bool
+	folds  map[int]int
+
+	// matchLine is the search match last scrolled to, and matchTop the row
+	// at the top of the view when it was, or -1
+	matchLine int
+	matchTop  int
+}
+
+// diffRow is a row of the diff view, which shows either a diff line or a
+// comment attached to the line. A line that's soft-wrapped takes a row for
+// each part.
+type diffRow struct {
+	line    int
+	part    int
+	comment int
+}
+
+func (r diffRow) isComment() bool {
+	return r.comment >= 0
 }
 
 func newDiffModel() diffModel {
-	m := diffModel{}
+	m := diffModel{opts: defaultDiffOptions(), folded: map[int]bool{}, matchLine: -1}
 	m.listModel.init(0, true)
 	return m
 }
@@ -33,58 +105,516 @@ func (m *diffModel) setDiff(c commitRange, s stat) {
 func (m *diffModel) setDiffStat(s stat) {
 	m.path = s.Path
 	m.oldPath = s.OldPath
+	m.stat = s
+	m.changes = nil
+	m.wsRules = gitWhitespaceRules(s.Path)
+	m.tabWidth = fileTabWidth(s.Path, m.wsRules)
 	m.refresh()
 }
 
+// getTabWidth returns the tab width set in the options, or the width
+// configured for the file
+func (m diffModel) getTabWidth() int {
+	if m.opts.tabWidth > 0 {
+		return m.opts.tabWidth
+	}
+	return m.tabWidth
+}
+
 func (m *diffModel) refresh() {
-	m.diff = gitDiff(m.commits.start, m.commits.end, m.path, m.oldPath, m.opts)
-	m.listModel.setCount(len(m.diff))
+	if m.stat.Binary {
+		m.diff = describeBinary(m.stat)
+	} else {
+		m.diff = gitDiff(m.commits.start, m.commits.end, m.path, m.oldPath, m.opts)
+	}
+	m.matchLine = -1
+
+	// the hunks may have changed, so the folds start over. Generated files
+	// start folded.
+	m.folded = map[int]bool{}
+	if m.stat.Generated {
+		for i := range m.hunkStarts() {
+			m.folded[i] = true
+		}
+	}
+	m.buildRows()
 }
 
-func (m diffModel) renderDiffLine(index int) string {
-	d := m.diff[index]
-	d = strings.ReplaceAll(d, "\t", "    ")
+// buildRows lays out the diff lines and the comments attached to them
+func (m *diffModel) buildRows() {
+	m.lineNums = numberDiffLines(m.diff)
+	m.maxLineNum = 0
+	for _, n := range m.lineNums {
+		m.maxLineNum = max(m.maxLineNum, n.old, n.new)
+	}
+
+	m.moved = nil
+	if m.moves != nil && !m.stat.Binary {
+		m.moved = m.moves.forFile(m.path, m.lineNums)
+	}
+
+	m.highlights = nil
+	if lang := languageFor(m.path); lang != nil && !m.stat.Binary {
+		m.highlights = highlightDiff(lang, m.diff)
+	}
+
+	attached := map[int][]int{}
+	m.fileComments = nil
+	if m.comments != nil {
+		m.fileComments = m.comments.forFile(m.commits, m.path)
+		for i, c := range m.fileComments {
+			if line := resolveComment(c, m.diff, m.lineNums); line >= 0 {
+				attached[line] = append(attached[line], i)
+			}
+		}
+	}
+
+	m.folds = m.foldedLines()
+	hidden := 0
+
+	m.rows = nil
+	for i := range m.diff {
+		if hidden > 0 {
+			hidden--
+			continue
+		}
+		hidden = m.folds[i]
+
+		for part := 0; part < m.lineParts(i); part++ {
+			m.rows = append(m.rows, diffRow{line: i, part: part, comment: -1})
+		}
+		for _, c := range attached[i] {
+			m.rows = append(m.rows, diffRow{line: i, comment: c})
+		}
+	}
+
+	m.setQuery(m.query)
+	m.listModel.setCount(len(m.rows))
+}
+
+// setQuery sets the search whose matches are highlighted and counted
+func (m *diffModel) setQuery(re *regexp.Regexp) {
+	m.query = re
+	m.found = nil
+	if re != nil {
+		m.found = m.matches(re)
+	}
+}
+
+// rowOfLine returns the index of the row showing a diff line
+func (m diffModel) rowOfLine(line int) int {
+	for i, r := range m.rows {
+		if r.line == line && !r.isComment() {
+			return i
+		}
+	}
+	return -1
+}
+
+// topLine returns the index of the diff line at the top of the view
+func (m diffModel) topLine() int {
+	if m.start >= len(m.rows) {
+		return -1
+	}
+	return m.rows[m.start].line
+}
+
+// scrollToLine scrolls a diff line to the top of the view
+func (m *diffModel) scrollToLine(line int) {
+	row := m.rowOfLine(line)
+	if row < 0 && m.unfoldLine(line) {
+		row = m.rowOfLine(line)
+	}
+	if row >= 0 {
+		m.scrollTo(row)
+	}
+}
+
+// hunkStarts returns the indexes of the hunk header lines
+func (m diffModel) hunkStarts() []int {
+	var starts []int
+	for i, line := range m.diff {
+		if strings.HasPrefix(line, "@@") {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// currentHunk returns the index of the hunk containing the line at the top of
+// the view, or -1 if the top line is above the first hunk
+func (m diffModel) currentHunk() int {
+	top := m.topLine()
+	current := -1
+	for i, start := range m.hunkStarts() {
+		if start > top {
+			break
+		}
+		current = i
+	}
+	return current
+}
+
+// getHunkStr describes the position of the current hunk
+func (m diffModel) getHunkStr() string {
+	count := len(m.hunkStarts())
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("hunk %d/%d", m.currentHunk()+1, count)
+}
+
+// nextHunk scrolls to the next hunk header below the top of the view
+func (m *diffModel) nextHunk() {
+	top := m.topLine()
+	for _, start := range m.hunkStarts() {
+		if start > top {
+			m.scrollToLine(start)
+			return
+		}
+	}
+}
+
+// prevHunk scrolls to the previous hunk header above the top of the view
+func (m *diffModel) prevHunk() {
+	top := m.topLine()
+	starts := m.hunkStarts()
+	for i := len(starts) - 1; i >= 0; i-- {
+		if starts[i] < top {
+			m.scrollToLine(starts[i])
+			return
+		}
+	}
+}
+
+// stickyHeader returns the index of the header of the hunk that's scrolled
+// partly out of view, or -1
+func (m diffModel) stickyHeader() int {
+	if hunk := m.currentHunk(); hunk >= 0 {
+		if start := m.hunkStarts()[hunk]; start < m.topLine() {
+			return start
+		}
+	}
+	return -1
+}
+
+// addComment attaches a comment to the line at the top of the view
+func (m *diffModel) addComment(body string) error {
+	line := m.topLine()
+	if line < 0 {
+		return fmt.Errorf("no line selected")
+	}
+	c, ok := newComment(m.commits, m.path, m.diff, line, body)
+	if !ok {
+		return fmt.Errorf("comments must be on a line in a hunk")
+	}
+	if err := m.comments.add(c); err != nil {
+		return err
+	}
+	m.buildRows()
+	return nil
+}
+
+// removeComment removes the comment at the top of the view
+func (m *diffModel) removeComment() error {
+	if m.start >= len(m.rows) || !m.rows[m.start].isComment() {
+		return fmt.Errorf("scroll a comment to the top of the view to remove it")
+	}
+	if err := m.comments.remove(m.fileComments[m.rows[m.start].comment]); err != nil {
+		return err
+	}
+	m.buildRows()
+	return nil
+}
+
+// liveRefresh reloads the diff in response to a worktree change and marks the
+// lines that are new or changed since the previous version of the diff.
+// It returns true if any lines were marked.
+func (m *diffModel) liveRefresh() bool {
+	prev := m.diff
+	m.refresh()
+
+	if strings.Join(prev, "\n") == strings.Join(m.diff, "\n") {
+		return false
+	}
+
+	seen := map[string]int{}
+	for _, line := range prev {
+		seen[line]++
+	}
+
+	m.changes = map[int]bool{}
+	nums := numberDiffLines(m.diff)
+	hunk := -1
+	for i, line := range m.diff {
+		if strings.HasPrefix(line, "@@") {
+			hunk = i
+			continue
+		}
+		if !nums[i].added() && !nums[i].removed() {
+			continue
+		}
+		if seen[line] > 0 {
+			seen[line]--
+			continue
+		}
+		m.changes[i] = true
+		if hunk >= 0 {
+			m.changes[hunk] = true
+		}
+	}
+
+	m.changedAt = time.Now()
+	return len(m.changes) > 0
+}
+
+// expireChanges clears the change markers if they're older than the fade time
+func (m *diffModel) expireChanges() {
+	if m.changeFade > 0 && time.Since(m.changedAt) >= m.changeFade {
+		m.changes = nil
+	}
+}
+
+// clearChanges acknowledges the current change markers
+func (m *diffModel) clearChanges() {
+	m.changes = nil
+}
+
+// nextChange scrolls to the next marked line below the top of the view
+func (m *diffModel) nextChange() {
+	for i := m.topLine() + 1; i < len(m.diff); i++ {
+		if m.changes[i] {
+			m.scrollToLine(i)
+			return
+		}
+	}
+}
+
+// renderGutter renders the change marker and line numbers for a diff line,
+// or a blank gutter if index is -1
+func (m diffModel) renderGutter(index int) string {
+	gutter := ""
+	if len(m.changes) > 0 {
+		if index >= 0 && m.changes[index] {
+			gutter = diffChangedStyle.Render("▌")
+		} else {
+			gutter = " "
+		}
+	}
 
-	if len(d) > 0 {
-		switch d[0] {
-		case '-':
-			return diffRemStyle.Render(d)
-		case '+':
-			return diffAddStyle.Render(d)
-		case '@':
-			return diffSepStyle.Render(d)
+	if m.showLineNums {
+		width := len(strconv.Itoa(m.maxLineNum))
+		oldNum, newNum := "", ""
+		if index >= 0 {
+			if n := m.lineNums[index].old; n > 0 {
+				oldNum = strconv.Itoa(n)
+			}
+			if n := m.lineNums[index].new; n > 0 {
+				newNum = strconv.Itoa(n)
+			}
 		}
+		diffLineNumStyle.Width(width + 1)
+		gutter += diffLineNumStyle.Render(oldNum) + diffLineNumStyle.Render(newNum) + " "
 	}
 
-	return diffNormalStyle.Render(d)
+	return gutter
+}
+
+// isContentLine returns true if a diff line is a line of the file, rather
+// than a header
+func (m diffModel) isContentLine(index int) bool {
+	return m.lineNums[index] != lineNumbers{}
+}
+
+// contentSpans lays out the content of a diff line after its prefix, with
+// whitespace errors marked in added lines
+func (m diffModel) contentSpans(index int) []span {
+	line := m.diff[index]
+	spans := []span{{line[1:], tokPlain}}
+	if m.highlights != nil && m.highlights[index] != nil {
+		spans = m.highlights[index]
+	}
+	spans = matchSpans(spans, m.query)
+
+	var errs []bool
+	if line[0] == '+' {
+		errs = whitespaceErrors(line[1:], m.wsRules, m.getTabWidth())
+	}
+	return layoutSpans(spans, errs, m.getTabWidth(), m.opts.showWhitespace)
+}
+
+// styleLine splits a diff line into its prefix and laid out content, and
+// picks their styles
+func (m diffModel) styleLine(index int) styledLine {
+	line := m.diff[index]
+	if line == "" {
+		return styledLine{prefixStyle: diffNormalStyle, base: diffNormalStyle}
+	}
+
+	prefix := line[0]
+	if !m.isContentLine(index) {
+		style := diffLineStyle(line)
+		spans := matchSpans([]span{{line[1:], tokPlain}}, m.query)
+		spans = layoutSpans(spans, nil, m.getTabWidth(), false)
+		if count, ok := m.folds[index]; ok {
+			spans = append(spans, span{getFoldStr(count), tokNote})
+		}
+		return styledLine{
+			prefix:      line[:1],
+			prefixStyle: style,
+			spans:       spans,
+			base:        style,
+		}
+	}
+
+	spans := m.contentSpans(index)
+	if l, ok := m.moved[index]; ok {
+		style := movedStyle(l)
+		return styledLine{
+			prefix:      line[:1],
+			prefixStyle: style,
+			spans:       movedSpans(spans, l),
+			base:        style,
+		}
+	}
+
+	// highlighted lines are tinted, and other lines are colored
+	l := styledLine{prefix: line[:1], spans: spans}
+	var bg lipgloss.TerminalColor
+	switch prefix {
+	case '+':
+		l.prefixStyle, bg = diffAddStyle, diffAddBg
+	case '-':
+		l.prefixStyle, bg = diffRemStyle, diffRemBg
+	default:
+		l.prefixStyle = diffNormalStyle
+	}
+	l.base = l.prefixStyle
+	if m.highlights != nil {
+		l.base = tokenStyles[tokPlain]
+		if bg != nil {
+			l.prefixStyle = l.prefixStyle.Copy()
+			l.prefixStyle.Background(bg)
+			l.bg = bg
+		}
+	}
+	return l
+}
+
+// renderDiffLine renders the first screen row of a diff line
+func (m diffModel) renderDiffLine(index int) string {
+	return m.renderLinePart(index, 0, false)
+}
+
+func (m diffModel) renderComment(index int) string {
+	c := m.fileComments[index]
+	return m.renderGutter(-1) + diffCommentStyle.Render("  ┃ "+c.Body)
 }
 
 func (m diffModel) render() string {
 	var lines []string
-	for i := m.start; i < m.end; i++ {
-		lines = append(lines, m.renderDiffLine(i))
+	start, end := m.start, m.end
+
+	// pin the header of the current hunk to the top of the view, in the row
+	// kept for it, or use that row for another line
+	if header := m.stickyHeader(); header >= 0 && end > start {
+		lines = append(lines, diffStickyStyle.Render(m.renderDiffLine(header)))
+	} else {
+		end = min(end+1, m.count)
+	}
+
+	for i := start; i < end; i++ {
+		r := m.rows[i]
+		if r.isComment() {
+			lines = append(lines, m.renderComment(r.comment))
+		} else {
+			selected := m.commenting && i == m.start
+			lines = append(lines, m.renderLinePart(r.line, r.part, selected))
+		}
 	}
 	return lipgloss.JoinVertical(lipgloss.Left, lines...)
 }
 
-func (m *diffModel) findNext(query string) {
-	q := strings.ToLower(query)
-	for i := m.cursor + 1; i < m.count; i++ {
-		c := strings.ToLower(m.renderDiffLine(i))
-		if strings.Contains(c, q) {
-			m.cursor = i
-			break
-		}
+// searchText returns the text of a diff line that searches match: the
+// content of lines of the file, or the whole line for headers
+func (m diffModel) searchText(index int) string {
+	if m.isContentLine(index) {
+		return m.diff[index][1:]
 	}
+	return m.diff[index]
 }
 
-func (m *diffModel) findPrev(query string) {
-	q := strings.ToLower(query)
-	for i := m.cursor - 1; i >= 0; i++ {
-		c := strings.ToLower(m.renderDiffLine(i))
-		if strings.Contains(c, q) {
-			m.cursor = i
-			break
+// matches returns the indexes of the diff lines that match re
+func (m diffModel) matches(re *regexp.Regexp) []int {
+	var matches []int
+	for i := range m.diff {
+		if re.MatchString(m.searchText(i)) {
+			matches = append(matches, i)
 		}
 	}
-}
\ No newline at end of file
+	return matches
+}
+
+// searchPos returns the line searches continue from: the match last scrolled
+// to if the view hasn't scrolled since, as a match near the end of the file
+// can't be scrolled to the top, or else the line at the top of the view
+func (m diffModel) searchPos() int {
+	if m.matchLine >= 0 && m.start == m.matchTop {
+		return m.matchLine
+	}
+	return m.topLine()
+}
+
+// scrollToMatch scrolls to a search match, unfolding its hunk if needed
+func (m *diffModel) scrollToMatch(line int) {
+	m.scrollToLine(line)
+	m.matchLine = line
+	m.matchTop = m.start
+}
+
+func (m *diffModel) findNext(re *regexp.Regexp) {
+	if i := nextMatch(m.matches(re), m.searchPos()); i >= 0 {
+		m.scrollToMatch(i)
+	}
+}
+
+func (m *diffModel) findPrev(re *regexp.Regexp) {
+	if i := prevMatch(m.matches(re), m.searchPos()); i >= 0 {
+		m.scrollToMatch(i)
+	}
+}
+
+// setContext changes the number of context lines by delta
+func (m *diffModel) setContext(delta int) {
+	m.opts.context = max(m.opts.context+delta, 0)
+	m.opts.contextMode = ""
+	m.refresh()
+}
+
+// nextContextMode cycles between normal context, function context and
+// showing the full file
+func (m *diffModel) nextContextMode() {
+	switch m.opts.contextMode {
+	case "":
+		m.opts.contextMode = "function"
+	case "function":
+		m.opts.contextMode = "full"
+	default:
+		m.opts.contextMode = ""
+	}
+	m.refresh()
+}
+
+// getContextStr describes the context setting if it isn't the default
+func (m diffModel) getContextStr() string {
+	switch m.opts.contextMode {
+	case "function":
+		return "Ufn"
+	case "full":
+		return "Uall"
+	}
+	if m.opts.context != defaultContext {
+		return fmt.Sprintf("U%d", m.opts.context)
+	}
+	return ""
+}
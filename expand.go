@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the number of lines added when expanding the context of a hunk
+const expandStep = 10
+
+var fullHunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@(.*)$`)
+
+type hunkHeader struct {
+	oldStart int
+	oldCount int
+	newStart int
+	newCount int
+	section  string
+}
+
+func parseHunkHeader(line string) (h hunkHeader, ok bool) {
+	match := fullHunkHeaderRe.FindStringSubmatch(line)
+	if match == nil {
+		return h, false
+	}
+
+	h.oldStart, _ = strconv.Atoi(match[1])
+	h.oldCount = 1
+	if match[2] != "" {
+		h.oldCount, _ = strconv.Atoi(match[2])
+	}
+	h.newStart, _ = strconv.Atoi(match[3])
+	h.newCount = 1
+	if match[4] != "" {
+		h.newCount, _ = strconv.Atoi(match[4])
+	}
+	h.section = match[5]
+
+	return h, true
+}
+
+func (h hunkHeader) String() string {
+	return fmt.Sprintf(
+		"@@ -%d,%d +%d,%d @@%s",
+		h.oldStart,
+		h.oldCount,
+		h.newStart,
+		h.newCount,
+		h.section,
+	)
+}
+
+// firstLines returns the numbers of the first old and new lines of a hunk.
+// Git numbers an empty side with the line before the change.
+func (h hunkHeader) firstLines() (int, int) {
+	oldFirst, newFirst := h.oldStart, h.newStart
+	if h.oldCount == 0 {
+		oldFirst++
+	}
+	if h.newCount == 0 {
+		newFirst++
+	}
+	return oldFirst, newFirst
+}
+
+// fileLines returns the lines of the new version of the file being shown, or
+// of the old version if the file was deleted
+func (m *diffModel) fileLines() []string {
+	var blob blobInfo
+	if m.stat.Status == "D" {
+		blob = readBlob(m.stat.OldHash, m.stat.Path, true)
+	} else {
+		blob = readBlob(m.stat.NewHash, m.stat.Path, false)
+	}
+	if !blob.exists {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(string(blob.data), "\n"), "\n")
+}
+
+// hunkEnd returns the index of the last line of the hunk starting at start
+func (m diffModel) hunkEnd(start int) int {
+	end := start
+	for i := start + 1; i < len(m.diff); i++ {
+		line := m.diff[i]
+		if strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "diff ") {
+			break
+		}
+		end = i
+	}
+	return end
+}
+
+// normalizeSpace removes the whitespace differences that the whitespace
+// options ignore from a line
+func normalizeSpace(line string, opts diffOptions) string {
+	switch {
+	case opts.ignoreWhitespace:
+		return strings.Join(strings.Fields(line), "")
+	case opts.ignoreSpaceChange:
+		return strings.Join(strings.Fields(line), " ")
+	case opts.ignoreSpaceAtEol:
+		return strings.TrimRight(line, " \t\r")
+	case opts.ignoreCrAtEol:
+		return strings.TrimSuffix(line, "\r")
+	}
+	return line
+}
+
+// hunkMatches returns true if the lines of the hunk starting at start match
+// the file's lines from fileStart, apart from whitespace the options ignore.
+// The file is read from the worktree, which can change while the diff isn't
+// refreshed.
+func (m diffModel) hunkMatches(start int, lines []string, fileStart int, deleted bool) bool {
+	// the prefix of the lines that aren't in the file's version
+	skip := "-"
+	if deleted {
+		skip = "+"
+	}
+	n := fileStart
+	for _, line := range m.diff[start+1 : m.hunkEnd(start)+1] {
+		if line == "" || strings.HasPrefix(line, skip) || strings.HasPrefix(line, "\\") {
+			continue
+		}
+		if n < 1 || n > len(lines) || normalizeSpace(lines[n-1], m.opts) != normalizeSpace(line[1:], m.opts) {
+			return false
+		}
+		n++
+	}
+	return true
+}
+
+// expandHunk adds up to expandStep lines of unchanged context above or below
+// the hunk at the top of the view, without reloading the diff. Context never
+// extends into a neighboring hunk.
+func (m *diffModel) expandHunk(above bool) error {
+	if m.stat.Binary {
+		return fmt.Errorf("can't expand a binary diff")
+	}
+
+	starts := m.hunkStarts()
+	if len(starts) == 0 {
+		return fmt.Errorf("no hunks")
+	}
+	// use the first hunk if the top of the view is above it
+	hunk := max(m.currentHunk(), 0)
+	start := starts[hunk]
+	header, ok := parseHunkHeader(m.diff[start])
+	if !ok {
+		return fmt.Errorf("can't parse hunk header")
+	}
+
+	// once context is added neither side is empty, so number both sides
+	// from their first line
+	header.oldStart, header.newStart = header.firstLines()
+
+	lines := m.fileLines()
+	// the side of the diff that the file lines come from
+	deleted := m.stat.Status == "D"
+	fileStart := header.newStart
+	if deleted {
+		fileStart = header.oldStart
+	}
+	if !m.hunkMatches(start, lines, fileStart, deleted) {
+		return fmt.Errorf("the file has changed since the diff was loaded")
+	}
+
+	var context []string
+	var at int
+	if above {
+		// the first line number available above the hunk
+		first := 1
+		if hunk > 0 {
+			prev, _ := parseHunkHeader(m.diff[starts[hunk-1]])
+			prevOld, prevNew := prev.firstLines()
+			first = prevNew + prev.newCount
+			if deleted {
+				first = prevOld + prev.oldCount
+			}
+		}
+		from := max(fileStart-expandStep, first)
+		for n := from; n < fileStart && n <= len(lines); n++ {
+			context = append(context, " "+lines[n-1])
+		}
+		header.oldStart -= len(context)
+		header.newStart -= len(context)
+		at = start + 1
+	} else {
+		fileEnd := fileStart + header.newCount
+		if deleted {
+			fileEnd = fileStart + header.oldCount
+		}
+		// the last line number available below the hunk
+		last := len(lines)
+		if hunk < len(starts)-1 {
+			next, _ := parseHunkHeader(m.diff[starts[hunk+1]])
+			nextOld, nextNew := next.firstLines()
+			last = nextNew - 1
+			if deleted {
+				last = nextOld - 1
+			}
+		}
+		to := min(fileEnd+expandStep-1, last)
+		for n := fileEnd; n <= to && n <= len(lines); n++ {
+			context = append(context, " "+lines[n-1])
+		}
+		at = m.hunkEnd(start) + 1
+	}
+
+	if len(context) == 0 {
+		return fmt.Errorf("no more context")
+	}
+
+	header.oldCount += len(context)
+	header.newCount += len(context)
+	m.diff[start] = header.String()
+
+	diff := append([]string{}, m.diff[:at]...)
+	diff = append(diff, context...)
+	m.diff = append(diff, m.diff[at:]...)
+
+	m.changes = nil
+	m.buildRows()
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// statSortModes are the orders the stats view can be sorted in, in the order
+// they're cycled through
+var statSortModes = []string{"path", "churn", "adds", "dels", "status"}
+
+// statFilter selects stats by path and status. A filter string is a list of
+// space-separated terms. A term is a glob, or a regular expression if it
+// starts with "re:", and is negated if it starts with "!". A term of the form
+// "status:AM" selects files by status. A file is shown if it matches any
+// positive path term (or there are none), no negated terms, and any status
+// term.
+type statFilter struct {
+	text     string
+	include  []func(string) bool
+	exclude  []func(string) bool
+	statuses string
+}
+
+func parseStatFilter(text string) (f statFilter, err error) {
+	f.text = text
+	for _, term := range strings.Fields(text) {
+		if strings.HasPrefix(term, "status:") {
+			f.statuses += strings.ToUpper(strings.TrimPrefix(term, "status:"))
+			continue
+		}
+
+		negated := strings.HasPrefix(term, "!")
+		term = strings.TrimPrefix(term, "!")
+
+		var match func(string) bool
+		if strings.HasPrefix(term, "re:") {
+			re, err := regexp.Compile(strings.TrimPrefix(term, "re:"))
+			if err != nil {
+				return f, err
+			}
+			match = re.MatchString
+		} else {
+			if _, err := path.Match(term, ""); err != nil {
+				return f, fmt.Errorf("bad pattern %q", term)
+			}
+			match = globMatcher(term)
+		}
+
+		if negated {
+			f.exclude = append(f.exclude, match)
+		} else {
+			f.include = append(f.include, match)
+		}
+	}
+	return
+}
+
+// globMatcher returns a function that matches a glob against a whole path,
+// or against the last element of the path if the glob has no slashes
+func globMatcher(glob string) func(string) bool {
+	return func(p string) bool {
+		if !strings.Contains(glob, "/") {
+			p = path.Base(p)
+		}
+		ok, _ := path.Match(glob, p)
+		return ok
+	}
+}
+
+func (f statFilter) isEmpty() bool {
+	return f.text == ""
+}
+
+func (f statFilter) match(s stat) bool {
+	if f.statuses != "" && !strings.Contains(f.statuses, s.Status) {
+		return false
+	}
+
+	for _, m := range f.exclude {
+		if m(s.Path) {
+			return false
+		}
+	}
+
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, m := range f.include {
+		if m(s.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortStats returns the indexes of the stats that match filter, in the given
+// sort order. Numeric orders are largest first.
+func sortStats(stats []stat, filter statFilter, by string) []int {
+	var order []int
+	for i, s := range stats {
+		if filter.match(s) {
+			order = append(order, i)
+		}
+	}
+
+	less := func(a, b stat) bool {
+		return a.Path < b.Path
+	}
+	switch by {
+	case "churn":
+		less = func(a, b stat) bool {
+			return a.Adds+a.Dels > b.Adds+b.Dels
+		}
+	case "adds":
+		less = func(a, b stat) bool {
+			return a.Adds > b.Adds
+		}
+	case "dels":
+		less = func(a, b stat) bool {
+			return a.Dels > b.Dels
+		}
+	case "status":
+		less = func(a, b stat) bool {
+			return a.Status < b.Status
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return less(stats[order[i]], stats[order[j]])
+	})
+
+	return order
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// gitGeneratedPaths returns the paths that .gitattributes marks as generated
+// with linguist-generated, or as not diffable with -diff
+func gitGeneratedPaths(paths []string) map[string]bool {
+	generated := map[string]bool{}
+	if len(paths) == 0 {
+		return generated
+	}
+
+	// pass the paths on stdin, since there can be too many for the command
+	// line
+	cmd := exec.Command("git", "check-attr", "--stdin", "-z", "linguist-generated", "diff")
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	out, err := cmd.Output()
+	if err != nil {
+		return generated
+	}
+
+	// the output is path, attribute and value triples
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		path, attr, value := fields[i], fields[i+1], fields[i+2]
+		switch {
+		case attr == "linguist-generated" && (value == "set" || value == "true"):
+			generated[path] = true
+		case attr == "diff" && value == "unset":
+			generated[path] = true
+		}
+	}
+	return generated
+}
+
+// markGenerated sets Generated on the stats of generated files
+func markGenerated(stats []stat) {
+	paths := make([]string, len(stats))
+	for i, s := range stats {
+		paths[i] = s.Path
+	}
+	generated := gitGeneratedPaths(paths)
+	for i := range stats {
+		stats[i].Generated = generated[stats[i].Path]
+	}
+}
+
+// hunkOfLine returns the index of the hunk containing a diff line, or -1
+func (m diffModel) hunkOfLine(line int) int {
+	hunk := -1
+	for i, start := range m.hunkStarts() {
+		if start > line {
+			break
+		}
+		hunk = i
+	}
+	return hunk
+}
+
+// foldedLines returns the number of lines hidden by each folded hunk, indexed
+// by the line of its header
+func (m diffModel) foldedLines() map[int]int {
+	folds := map[int]int{}
+	for i, start := range m.hunkStarts() {
+		if m.folded[i] {
+			folds[start] = m.hunkEnd(start) - start
+		}
+	}
+	return folds
+}
+
+// isFolded returns true if a line is hidden inside a folded hunk
+func (m diffModel) isFolded(line int) bool {
+	for start, count := range m.folds {
+		if line > start && line <= start+count {
+			return true
+		}
+	}
+	return false
+}
+
+// toggleFold folds or unfolds the hunk at the top of the view
+func (m *diffModel) toggleFold() error {
+	if len(m.hunkStarts()) == 0 {
+		return fmt.Errorf("no hunks")
+	}
+	// use the first hunk if the top of the view is above it
+	hunk := max(m.currentHunk(), 0)
+	m.folded[hunk] = !m.folded[hunk]
+	m.buildRows()
+	m.scrollToLine(m.hunkStarts()[hunk])
+	return nil
+}
+
+// foldAll folds every hunk of the file to its header
+func (m *diffModel) foldAll() {
+	hunk := m.currentHunk()
+	starts := m.hunkStarts()
+	for i := range starts {
+		m.folded[i] = true
+	}
+	m.buildRows()
+	if hunk >= 0 {
+		m.scrollToLine(starts[hunk])
+	}
+}
+
+// unfoldAll shows every hunk of the file
+func (m *diffModel) unfoldAll() {
+	m.folded = map[int]bool{}
+	m.relayout()
+}
+
+// unfoldLine unfolds the hunk hiding a line. It returns false if the line
+// isn't folded.
+func (m *diffModel) unfoldLine(line int) bool {
+	if !m.isFolded(line) {
+		return false
+	}
+	m.folded[m.hunkOfLine(line)] = false
+	m.buildRows()
+	return true
+}
+
+// getFoldStr describes a folded hunk
+func getFoldStr(count int) string {
+	if count == 1 {
+		return "  ⋯ 1 line folded"
+	}
+	return fmt.Sprintf("  ⋯ %d lines folded", count)
+}
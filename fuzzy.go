@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// the number of results the fuzzy finder shows
+const fuzzyMaxResults = 10
+
+// scores of a fuzzy match. Matched characters score more when they follow
+// the previous match or start a word, and each skipped character costs a
+// point.
+const (
+	fuzzyMatchScore       = 16
+	fuzzyConsecutiveBonus = 8
+	fuzzyBoundaryBonus    = 10
+	fuzzyGapPenalty       = 1
+)
+
+// fuzzyItem is a candidate of the fuzzy finder, the text of a row of a view.
+// The row is found again on select by the commit hash or the path, since the
+// rows can change while the finder is open.
+type fuzzyItem struct {
+	commit string
+	path   string
+	text   string
+}
+
+// fuzzyResult is an item matching the query, with the positions of the
+// matched runes
+type fuzzyResult struct {
+	item      fuzzyItem
+	score     int
+	positions []int
+}
+
+// fuzzyModel is a popup that ranks the rows of a view by a fuzzy query as it's
+// typed
+type fuzzyModel struct {
+	active bool
+	// view is the name of the view whose rows are searched
+	view    string
+	query   string
+	items   []fuzzyItem
+	results []fuzzyResult
+	cursor  int
+}
+
+func (m *fuzzyModel) open(view string, items []fuzzyItem) {
+	m.active = true
+	m.view = view
+	m.query = ""
+	m.items = items
+	m.rank()
+}
+
+func (m *fuzzyModel) close() {
+	m.active = false
+	m.items = nil
+	m.results = nil
+}
+
+func (m *fuzzyModel) setQuery(query string) {
+	m.query = query
+	m.rank()
+}
+
+// rank matches the items against the query, best first. Ties keep the
+// shorter item first, then the order of the view. An empty query keeps every
+// item in the order of the view.
+func (m *fuzzyModel) rank() {
+	m.results = nil
+	m.cursor = 0
+	for _, item := range m.items {
+		if score, positions, ok := fuzzyMatch(m.query, item.text); ok {
+			m.results = append(m.results, fuzzyResult{item, score, positions})
+		}
+	}
+	if m.query == "" {
+		return
+	}
+	sort.SliceStable(m.results, func(i, j int) bool {
+		a, b := m.results[i], m.results[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return len(a.item.text) < len(b.item.text)
+	})
+}
+
+func (m *fuzzyModel) next() {
+	m.cursor = max(min(m.cursor+1, min(len(m.results), fuzzyMaxResults)-1), 0)
+}
+
+func (m *fuzzyModel) prev() {
+	m.cursor = max(m.cursor-1, 0)
+}
+
+// selected returns the item at the cursor, or false if nothing matches
+func (m fuzzyModel) selected() (fuzzyItem, bool) {
+	if m.cursor < 0 || m.cursor >= len(m.results) {
+		return fuzzyItem{}, false
+	}
+	return m.results[m.cursor].item, true
+}
+
+func isWordBoundary(text []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := text[i-1]
+	if strings.ContainsRune(" /-_.:", prev) {
+		return true
+	}
+	return unicode.IsLower(prev) && unicode.IsUpper(text[i])
+}
+
+// fuzzyMatch matches the runes of pattern in order anywhere in text, ignoring
+// case. It tries each place the first rune matches and keeps the best score.
+func fuzzyMatch(pattern, text string) (int, []int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	if len(p) == 0 {
+		return 0, nil, true
+	}
+	orig := []rune(text)
+	t := []rune(strings.ToLower(text))
+	if len(t) != len(orig) {
+		// lowercasing changed the length, so match the text as it is
+		t = orig
+	}
+
+	bestScore := 0
+	var best []int
+	for start := range t {
+		if t[start] != p[0] {
+			continue
+		}
+
+		positions := []int{start}
+		for i, j := 1, start+1; i < len(p) && j < len(t); j++ {
+			if t[j] == p[i] {
+				positions = append(positions, j)
+				i++
+			}
+		}
+		if len(positions) < len(p) {
+			// later starts can't match either
+			break
+		}
+
+		score := 0
+		for k, pos := range positions {
+			score += fuzzyMatchScore
+			if isWordBoundary(orig, pos) {
+				score += fuzzyBoundaryBonus
+			}
+			if k > 0 {
+				if pos == positions[k-1]+1 {
+					score += fuzzyConsecutiveBonus
+				} else {
+					score -= fuzzyGapPenalty * (pos - positions[k-1] - 1)
+				}
+			}
+		}
+		if best == nil || score > bestScore {
+			bestScore, best = score, positions
+		}
+	}
+
+	return bestScore, best, best != nil
+}
+
+// fuzzyItems returns the fuzzy finder items of the commits view
+func (m commitsModel) fuzzyItems() []fuzzyItem {
+	items := make([]fuzzyItem, len(m.commits))
+	for i, c := range m.commits {
+		text := fmt.Sprintf("%s %s (%s)", trunc(c.Commit, 8), c.Subject, c.AuthorName)
+		items[i] = fuzzyItem{commit: c.Commit, text: text}
+	}
+	return items
+}
+
+// fuzzyItems returns the fuzzy finder items of the stats view, the paths of
+// its file rows
+func (m statsModel) fuzzyItems() []fuzzyItem {
+	var items []fuzzyItem
+	for _, r := range m.rows {
+		if !r.isDir() {
+			path := m.stats[r.index].Path
+			items = append(items, fuzzyItem{path: path, text: path})
+		}
+	}
+	return items
+}
+
+// getFuzzyStr describes the query and the number of results
+func (m fuzzyModel) getFuzzyStr() string {
+	return fmt.Sprintf("find: %s (%d/%d)", m.query, len(m.results), len(m.items))
+}
+
+func (m fuzzyModel) renderResult(index, width int) string {
+	r := m.results[index]
+
+	style := fuzzyStyle
+	match := fuzzyMatchStyle
+	if index == m.cursor {
+		style.Background(cursorBg)
+		match.Background(cursorBg)
+	} else {
+		style.UnsetBackground()
+		match.UnsetBackground()
+	}
+
+	matched := map[int]bool{}
+	for _, pos := range r.positions {
+		matched[pos] = true
+	}
+
+	// truncate and pad by display width, since wide runes take two columns
+	var b strings.Builder
+	used := 0
+	for i, c := range []rune(r.item.text) {
+		w := lipgloss.Width(string(c))
+		if used+w > width {
+			break
+		}
+		used += w
+		if matched[i] {
+			b.WriteString(match.Render(string(c)))
+		} else {
+			b.WriteString(style.Render(string(c)))
+		}
+	}
+	if pad := width - used; pad > 0 {
+		b.WriteString(style.Render(strings.Repeat(" ", pad)))
+	}
+	return b.String()
+}
+
+// render draws the popup, the best results below a rule, width columns wide
+func (m fuzzyModel) render(width int) []string {
+	lines := []string{fuzzyBorderStyle.Render(strings.Repeat("─", width))}
+	for i := 0; i < len(m.results) && i < fuzzyMaxResults; i++ {
+		lines = append(lines, m.renderResult(i, width))
+	}
+	if len(m.results) == 0 {
+		lines = append(lines, fuzzyStyle.Render("No matches"))
+	}
+	return lines
+}
+
+// overlay draws the popup over the bottom rows of a rendered view
+func (m fuzzyModel) overlay(view string, width, height int) string {
+	rows := strings.Split(lipgloss.PlaceVertical(height, lipgloss.Top, view), "\n")
+	popup := m.render(width)
+	if len(popup) > len(rows) {
+		popup = popup[len(popup)-len(rows):]
+	}
+	copy(rows[len(rows)-len(popup):], popup)
+	return strings.Join(rows, "\n")
+}
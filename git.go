@@ -4,14 +4,40 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// renameThreshold is the similarity index for rename detection, or 0 to turn
+// rename detection off
 var renameThreshold = 50
 
+// copyDetection is "copies" to detect copies of modified files, or "harder"
+// to detect copies of any file
+var copyDetection = ""
+
+// renameArgs returns the rename and copy detection arguments for git diff.
+// Copy detection needs rename detection, so it's off along with it.
+func renameArgs() []string {
+	if renameThreshold == 0 {
+		return []string{"--no-renames"}
+	}
+	args := []string{fmt.Sprintf("--find-renames=%d", renameThreshold)}
+	switch copyDetection {
+	case "copies":
+		args = append(args, "--find-copies")
+	case "harder":
+		args = append(args, "--find-copies-harder")
+	}
+	return args
+}
+
 type commit struct {
 	Commit      string
 	Decoration  string
@@ -55,6 +81,33 @@ func getGitDir() string {
 	return strings.TrimSpace(string(out))
 }
 
+// getGitPrefix returns the path of the current directory relative to the top
+// of the worktree, with a trailing slash, or an empty string at the top level
+func getGitPrefix() string {
+	out, err := exec.Command(
+		"git",
+		"rev-parse",
+		"--show-prefix").Output()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// getGitTopLevel returns the absolute path of the top of the worktree
+func getGitTopLevel() string {
+	out, err := exec.Command(
+		"git",
+		"rev-parse",
+		"--show-toplevel").Output()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
 func gitLog() []commit {
 	out, err := exec.Command(
 		"git",
@@ -87,51 +140,123 @@ type stat struct {
 	Dels    int
 	Path    string
 	OldPath string
+	// Status is the git status letter: A, M, D, R, C or T
+	Status string
+	// Score is the similarity percentage of a rename or copy
+	Score   int
+	OldMode string
+	NewMode string
+	OldHash string
+	NewHash string
+	// Binary is true if git doesn't count lines for the file
+	Binary bool
+	// Generated is true if .gitattributes marks the file as generated
+	Generated bool
 }
 
-func gitDiffStat(start, end string) []stat {
-	commit := start
-	if end != "" {
-		commit += ".." + end
-	}
-
-	out, err := exec.Command(
-		"git",
-		"diff",
-		"--numstat",
-		fmt.Sprintf("--find-renames=%d", renameThreshold),
-		commit,
-	).Output()
-	if err != nil {
-		log.Fatal(err)
-	}
-
+// parseStats parses the output of a git diff command run with --raw and
+// --numstat. The raw lines come first and are in the same order as the
+// numstat lines.
+func parseStats(out string) []stat {
 	var stats []stat
+	var numstats []string
 
-	outStr := strings.TrimSuffix(string(out), "\n")
+	outStr := strings.TrimSuffix(out, "\n")
 	for _, line := range strings.Split(outStr, "\n") {
 		if len(line) == 0 {
 			continue
 		}
 
-		parts := strings.Split(line, "\t")
+		if line[0] != ':' {
+			numstats = append(numstats, line)
+			continue
+		}
 
-		s := stat{}
-		s.Adds, _ = strconv.Atoi(parts[0])
-		s.Dels, _ = strconv.Atoi(parts[1])
+		// combined diffs of merge commits aren't supported
+		if strings.HasPrefix(line, "::") {
+			continue
+		}
 
-		s.Path = strings.TrimSpace(parts[2])
-		if strings.Contains(s.Path, " => ") {
-			s.OldPath, s.Path, _ = strings.Cut(s.Path, " => ")
+		info, paths, _ := strings.Cut(line[1:], "\t")
+		fields := strings.Fields(info)
+		pathParts := strings.Split(paths, "\t")
+
+		s := stat{
+			OldMode: fields[0],
+			NewMode: fields[1],
+			OldHash: fields[2],
+			NewHash: fields[3],
+			Status:  fields[4][:1],
+			Path:    pathParts[0],
+		}
+		s.Score, _ = strconv.Atoi(fields[4][1:])
+		if len(pathParts) > 1 {
+			s.OldPath = pathParts[0]
+			s.Path = pathParts[1]
 		}
 		stats = append(stats, s)
 	}
 
+	for i, line := range numstats {
+		if i >= len(stats) {
+			break
+		}
+		parts := strings.Split(line, "\t")
+		stats[i].Binary = parts[0] == "-"
+		stats[i].Adds, _ = strconv.Atoi(parts[0])
+		stats[i].Dels, _ = strconv.Atoi(parts[1])
+	}
+
 	return stats
 }
 
+func gitDiffStat(start, end string) []stat {
+	commit := start
+	if end != "" {
+		commit += ".." + end
+	}
+
+	args := []string{"diff", "--raw", "--numstat", "--no-abbrev"}
+	args = append(args, renameArgs()...)
+	args = append(args, commit)
+
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return parseStats(string(out))
+}
+
+// defaultContext is git's default number of context lines
+const defaultContext = 3
+
+// diffAlgorithms are the diff algorithms git supports
+var diffAlgorithms = []string{"patience", "histogram", "myers", "minimal"}
+
 type diffOptions struct {
+	// algorithm is the diff algorithm, one of diffAlgorithms
+	algorithm        string
 	ignoreWhitespace bool
+	// ignoreSpaceChange ignores changes in the amount of whitespace
+	ignoreSpaceChange bool
+	ignoreBlankLines  bool
+	ignoreCrAtEol     bool
+	ignoreSpaceAtEol  bool
+	// context is the number of lines of context around changes
+	context int
+	// contextMode is "function" to show whole functions, or "full" to show
+	// whole files, as context around changes
+	contextMode string
+
+	// tabWidth and showWhitespace change how the diff is displayed rather
+	// than the git command. A tabWidth of 0 uses the file's tab width.
+	tabWidth       int
+	showWhitespace bool
+}
+
+func defaultDiffOptions() diffOptions {
+	return diffOptions{algorithm: diffAlgorithms[0], context: defaultContext}
 }
 
 func gitDiff(start, end, path, oldPath string, options diffOptions) []string {
@@ -147,17 +272,42 @@ func gitDiff(start, end, path, oldPath string, options diffOptions) []string {
 	args := []string{
 		"git",
 		command,
-		"--patience",
-		fmt.Sprintf("--find-renames=%d", renameThreshold),
+		"--diff-algorithm=" + options.algorithm,
 		"-p",
 	}
+	args = append(args, renameArgs()...)
 
 	if options.ignoreWhitespace {
 		args = append(args, "-w")
 	}
+	if options.ignoreSpaceChange {
+		args = append(args, "-b")
+	}
+	if options.ignoreBlankLines {
+		args = append(args, "--ignore-blank-lines")
+	}
+	if options.ignoreCrAtEol {
+		args = append(args, "--ignore-cr-at-eol")
+	}
+	if options.ignoreSpaceAtEol {
+		args = append(args, "--ignore-space-at-eol")
+	}
+
+	switch options.contextMode {
+	case "function":
+		args = append(args, "--function-context")
+	case "full":
+		args = append(args, fmt.Sprintf("-U%d", math.MaxInt32))
+	default:
+		args = append(args, fmt.Sprintf("-U%d", options.context))
+	}
 
-	args = append(args, commit, "--", path)
+	args = append(args, commit)
 
+	// an empty path diffs every file in the range
+	if path != "" {
+		args = append(args, "--", path)
+	}
 	if oldPath != "" {
 		args = append(args, oldPath)
 	}
@@ -203,38 +353,223 @@ func parseDecoration(decoration string) (d decor) {
 }
 
 func gitShow(commit string) []stat {
-	out, err := exec.Command(
-		"git",
-		"show",
-		"--numstat",
-		"--format=",
-		fmt.Sprintf("--find-renames=%d", renameThreshold),
-		commit,
-	).Output()
+	args := []string{"show", "--raw", "--numstat", "--no-abbrev", "--format="}
+	args = append(args, renameArgs()...)
+	args = append(args, commit)
+
+	out, err := exec.Command("git", args...).Output()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	var stats []stat
+	return parseStats(string(out))
+}
 
-	outStr := strings.TrimSuffix(string(out), "\n")
-	for _, line := range strings.Split(outStr, "\n") {
-		if len(line) == 0 {
+// gitListFiles returns the tracked files, and the untracked files that aren't
+// ignored, that match pathspecs. Paths are relative to the current directory.
+func gitListFiles(pathspecs []string) []string {
+	var files []string
+
+	for _, extra := range [][]string{{}, {"--others", "--exclude-standard"}} {
+		args := append([]string{"ls-files", "-z"}, extra...)
+		args = append(args, "--")
+		args = append(args, pathspecs...)
+
+		out, err := exec.Command("git", args...).Output()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, file := range strings.Split(string(out), "\x00") {
+			if len(file) != 0 {
+				files = append(files, file)
+			}
+		}
+	}
+
+	return files
+}
+
+// gitTrackedDirs returns the set of directories that contain tracked files or
+// untracked files that aren't ignored, along with all of their parents. Files
+// must match pathspecs, and also be in one of the cone directories unless cone
+// is empty. Paths are relative to the current directory.
+func gitTrackedDirs(pathspecs []string, cone []string) []string {
+	dirs := map[string]bool{".": true}
+
+	inCone := map[string]bool{}
+	if len(cone) > 0 {
+		for _, file := range gitListFiles(cone) {
+			inCone[file] = true
+		}
+	}
+
+	for _, file := range gitListFiles(pathspecs) {
+		if len(cone) > 0 && !inCone[file] {
 			continue
 		}
+		for dir := filepath.Dir(file); !dirs[dir]; dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
 
-		parts := strings.Split(line, "\t")
+	var list []string
+	for dir := range dirs {
+		list = append(list, dir)
+	}
+	sort.Strings(list)
+
+	return list
+}
 
-		s := stat{}
+// gitSparseCone returns the directories in the sparse-checkout cone as
+// pathspecs, or nil if the worktree isn't sparse. Outside of cone mode the
+// sparse-checkout list holds patterns rather than directories, so it's
+// ignored.
+func gitSparseCone() []string {
+	out, err := exec.Command("git", "config", "--bool", "core.sparseCheckoutCone").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "true" {
+		return nil
+	}
+
+	out, err = exec.Command("git", "sparse-checkout", "list").Output()
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			// the list is relative to the top of the worktree
+			paths = append(paths, ":(top)"+line)
+		}
+	}
+
+	return paths
+}
+
+// gitRevParse returns the full hash of a revision
+func gitRevParse(rev string) string {
+	out, err := exec.Command("git", "rev-parse", "--verify", rev).Output()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// gitFileStats returns the numstats of paths between a commit and the
+// worktree, indexed by path. Untracked paths count as added. Paths without
+// changes are left out.
+func gitFileStats(start string, paths []string) (map[string]stat, error) {
+	args := append([]string{"diff", "--numstat", "-z", "--no-renames", start, "--"}, paths...)
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]stat{}
+	for _, record := range strings.Split(string(out), "\x00") {
+		parts := strings.SplitN(record, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		s := stat{Path: parts[2]}
 		s.Adds, _ = strconv.Atoi(parts[0])
 		s.Dels, _ = strconv.Atoi(parts[1])
+		stats[s.Path] = s
+	}
 
-		s.Path = strings.TrimSpace(parts[2])
-		if strings.Contains(s.Path, " => ") {
-			s.OldPath, s.Path, _ = strings.Cut(s.Path, " => ")
+	args = append([]string{"ls-files", "-z", "--others", "--exclude-standard", "--"}, paths...)
+	out, err = exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range strings.Split(string(out), "\x00") {
+		if len(path) == 0 {
+			continue
 		}
-		stats = append(stats, s)
+		s, err := gitUntrackedStat(path)
+		if err != nil {
+			return nil, err
+		}
+		stats[path] = s
 	}
 
-	return stats
-}
\ No newline at end of file
+	return stats, nil
+}
+
+// gitUntrackedStat returns the numstat of an untracked file, all of whose
+// lines are added
+func gitUntrackedStat(path string) (stat, error) {
+	s := stat{Path: path, Status: "A"}
+	cmd := exec.Command("git", "diff", "--no-index", "--numstat", "--", os.DevNull, path)
+	out, err := cmd.Output()
+	// --no-index exits with 1 when the files differ
+	if exiterr, ok := err.(*exec.ExitError); ok && exiterr.ExitCode() == 1 {
+		err = nil
+	}
+	if err != nil {
+		return s, err
+	}
+
+	parts := strings.SplitN(string(out), "\t", 3)
+	if len(parts) == 3 {
+		s.Adds, _ = strconv.Atoi(parts[0])
+	}
+	return s, nil
+}
+
+// gitRangeDiff returns the output of git range-diff for the given arguments,
+// such as "old...new" or "base old new"
+func gitRangeDiff(args []string) ([]string, error) {
+	args = append([]string{"range-diff", "--no-color"}, args...)
+
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		// range-diff rejects ranges without a common base, for example
+		if exiterr, ok := err.(*exec.ExitError); ok && len(exiterr.Stderr) > 0 {
+			msg, _, _ := strings.Cut(strings.TrimSpace(string(exiterr.Stderr)), "\n")
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, err
+	}
+
+	outStr := strings.TrimSuffix(string(out), "\n")
+	return strings.Split(outStr, "\n"), nil
+}
+
+// gitShowPatch returns the patch introduced by a commit, without the commit
+// message
+func gitShowPatch(commit string, opts diffOptions) []string {
+	diff := gitDiff(commit, commit, "", "", opts)
+	for i, line := range diff {
+		if strings.HasPrefix(line, "diff --git ") {
+			return diff[i:]
+		}
+	}
+	return nil
+}
+
+// gitCatFile returns the contents of a blob
+func gitCatFile(hash string) []byte {
+	out, err := exec.Command("git", "cat-file", "blob", hash).Output()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return out
+}
+
+// gitHashObjects returns the blob hashes of worktree files. Paths are relative
+// to the top of the worktree.
+func gitHashObjects(paths []string) []string {
+	cmd := exec.Command("git", append([]string{"hash-object", "--"}, paths...)...)
+	cmd.Dir = getGitTopLevel()
+	out, err := cmd.Output()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return strings.Fields(string(out))
+}
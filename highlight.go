@@ -0,0 +1,422 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+type tokenKind int
+
+const (
+	tokPlain tokenKind = iota
+	tokKeyword
+	tokString
+	tokComment
+	tokNumber
+	tokKey
+	tokVariable
+	tokHeading
+	tokCode
+	tokEmphasis
+	tokWhitespace
+	tokWhitespaceError
+	tokNote
+	tokMatch
+)
+
+// span is a run of text with a single token kind
+type span struct {
+	text string
+	kind tokenKind
+}
+
+// delim is a comment or string delimiter. A delim with an empty close ends at
+// the end of the line.
+type delim struct {
+	open      string
+	close     string
+	kind      tokenKind
+	escapes   bool
+	multiline bool
+}
+
+// hlState is the highlighting state carried from one line to the next: 0, or
+// the index of the multi-line delim that's open plus one
+type hlState int
+
+// language describes how to highlight a language. Delims are tried in order,
+// so longer delimiters must come before their prefixes.
+type language struct {
+	delims    []delim
+	keywords  map[string]bool
+	variables bool
+	// custom highlights a line instead of the generic tokenizer
+	custom func(l *language, line string, st hlState) ([]span, hlState)
+}
+
+func keywordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+var cDelims = []delim{
+	{"//", "", tokComment, false, false},
+	{"/*", "*/", tokComment, false, true},
+	{`"`, `"`, tokString, true, false},
+	{"'", "'", tokString, true, false},
+}
+
+var goLang = &language{
+	delims: append([]delim{{"`", "`", tokString, false, true}}, cDelims...),
+	keywords: keywordSet(`break case chan const continue default defer else
+		fallthrough for func go goto if import interface map package range
+		return select struct switch type var true false nil iota`),
+}
+
+var jsLang = &language{
+	delims: append([]delim{{"`", "`", tokString, true, true}}, cDelims...),
+	keywords: keywordSet(`async await break case catch class const continue
+		debugger default delete do else enum export extends false finally for
+		from function if implements import in instanceof interface let new
+		null of private protected public return static super switch this
+		throw true try type typeof undefined var void while with yield`),
+}
+
+var pythonLang = &language{
+	delims: []delim{
+		{"#", "", tokComment, false, false},
+		{`"""`, `"""`, tokString, true, true},
+		{"'''", "'''", tokString, true, true},
+		{`"`, `"`, tokString, true, false},
+		{"'", "'", tokString, true, false},
+	},
+	keywords: keywordSet(`and as assert async await break class continue def
+		del elif else except False finally for from global if import in is
+		lambda None nonlocal not or pass raise return True try while with
+		yield self`),
+}
+
+var shellLang = &language{
+	delims: []delim{
+		{"#", "", tokComment, false, false},
+		{`"`, `"`, tokString, true, true},
+		{"'", "'", tokString, false, true},
+	},
+	keywords: keywordSet(`if then else elif fi case esac for while until do
+		done in function return local export readonly shift exit set unset
+		true false`),
+	variables: true,
+}
+
+var yamlLang = &language{
+	delims: []delim{
+		{"#", "", tokComment, false, false},
+		{`"`, `"`, tokString, true, false},
+		{"'", "'", tokString, false, false},
+	},
+	keywords: keywordSet(`true false null yes no on off ~`),
+	custom:   highlightYAML,
+}
+
+var jsonLang = &language{
+	delims:   []delim{{`"`, `"`, tokString, true, false}},
+	keywords: keywordSet(`true false null`),
+	custom:   highlightJSON,
+}
+
+var markdownLang = &language{custom: highlightMarkdown}
+
+var languages = map[string]*language{
+	".go":       goLang,
+	".js":       jsLang,
+	".jsx":      jsLang,
+	".mjs":      jsLang,
+	".cjs":      jsLang,
+	".ts":       jsLang,
+	".tsx":      jsLang,
+	".py":       pythonLang,
+	".sh":       shellLang,
+	".bash":     shellLang,
+	".zsh":      shellLang,
+	".yml":      yamlLang,
+	".yaml":     yamlLang,
+	".json":     jsonLang,
+	".md":       markdownLang,
+	".markdown": markdownLang,
+}
+
+// languageFor returns the language of a file from its extension, or nil
+func languageFor(path string) *language {
+	return languages[strings.ToLower(filepath.Ext(path))]
+}
+
+func (l *language) highlight(line string, st hlState) ([]span, hlState) {
+	if l.custom != nil {
+		return l.custom(l, line, st)
+	}
+	return l.tokenize(line, st)
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// findClose returns the index just past the close delimiter at or after from,
+// or -1 if the line ends first
+func findClose(line string, from int, d delim) int {
+	for i := from; i < len(line); i++ {
+		if d.escapes && line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(line[i:], d.close) {
+			return i + len(d.close)
+		}
+	}
+	return -1
+}
+
+// spanBuilder collects spans, merging neighbors of the same kind
+type spanBuilder []span
+
+func (b *spanBuilder) add(text string, kind tokenKind) {
+	if text == "" {
+		return
+	}
+	if n := len(*b); n > 0 && (*b)[n-1].kind == kind {
+		(*b)[n-1].text += text
+		return
+	}
+	*b = append(*b, span{text, kind})
+}
+
+// tokenize is the generic tokenizer for languages made of identifiers,
+// numbers, comments and strings
+func (l *language) tokenize(line string, st hlState) ([]span, hlState) {
+	var b spanBuilder
+	i := 0
+
+	if st > 0 {
+		d := l.delims[st-1]
+		end := findClose(line, 0, d)
+		if end < 0 {
+			b.add(line, d.kind)
+			return b, st
+		}
+		b.add(line[:end], d.kind)
+		i = end
+		st = 0
+	}
+
+	for i < len(line) {
+		c := line[i]
+
+		opened := false
+		for n, d := range l.delims {
+			if !strings.HasPrefix(line[i:], d.open) {
+				continue
+			}
+			opened = true
+			if d.close == "" {
+				b.add(line[i:], d.kind)
+				return b, st
+			}
+			end := findClose(line, i+len(d.open), d)
+			if end < 0 {
+				b.add(line[i:], d.kind)
+				if d.multiline {
+					st = hlState(n + 1)
+				}
+				return b, st
+			}
+			b.add(line[i:end], d.kind)
+			i = end
+			break
+		}
+		if opened {
+			continue
+		}
+
+		switch {
+		case l.variables && c == '$' && i+1 < len(line):
+			end := i + 1
+			if line[end] == '{' {
+				if close := strings.IndexByte(line[end:], '}'); close >= 0 {
+					end += close + 1
+				} else {
+					end = len(line)
+				}
+			} else {
+				for end < len(line) && isIdentByte(line[end]) {
+					end++
+				}
+				if end == i+1 {
+					end++
+				}
+			}
+			b.add(line[i:end], tokVariable)
+			i = end
+		case isDigit(c):
+			end := i
+			for end < len(line) && (isIdentByte(line[end]) || line[end] == '.') {
+				end++
+			}
+			b.add(line[i:end], tokNumber)
+			i = end
+		case isIdentByte(c):
+			end := i
+			for end < len(line) && isIdentByte(line[end]) {
+				end++
+			}
+			kind := tokPlain
+			if l.keywords[line[i:end]] {
+				kind = tokKeyword
+			}
+			b.add(line[i:end], kind)
+			i = end
+		default:
+			b.add(line[i:i+1], tokPlain)
+			i++
+		}
+	}
+
+	return b, st
+}
+
+// highlightJSON highlights strings that are followed by a colon as keys
+func highlightJSON(l *language, line string, st hlState) ([]span, hlState) {
+	spans, st := l.tokenize(line, st)
+	for i, s := range spans {
+		if s.kind != tokString {
+			continue
+		}
+		for _, next := range spans[i+1:] {
+			rest := strings.TrimLeft(next.text, " \t")
+			if rest == "" {
+				continue
+			}
+			if rest[0] == ':' {
+				spans[i].kind = tokKey
+			}
+			break
+		}
+	}
+	return spans, st
+}
+
+var yamlKeyRe = regexp.MustCompile(`^(\s*(?:- )?)([^\s:#'"-][^:#]*?|"[^"]*"|'[^']*')(:)(\s|$)`)
+
+// highlightYAML highlights the key of a mapping line and tokenizes the value
+func highlightYAML(l *language, line string, st hlState) ([]span, hlState) {
+	match := yamlKeyRe.FindStringSubmatchIndex(line)
+	if match == nil {
+		return l.tokenize(line, st)
+	}
+
+	b := spanBuilder{}
+	b.add(line[:match[3]], tokPlain)
+	b.add(line[match[4]:match[5]], tokKey)
+	rest, st := l.tokenize(line[match[6]:], st)
+	for _, s := range rest {
+		b.add(s.text, s.kind)
+	}
+	return b, st
+}
+
+var markdownHeadingRe = regexp.MustCompile(`^\s{0,3}#{1,6}(\s|$)`)
+var markdownInlineRe = regexp.MustCompile("`[^`]+`|\\*\\*[^*]+\\*\\*|__[^_]+__|\\*[^*\\s][^*]*\\*")
+
+// highlightMarkdown highlights headings, fenced code blocks, inline code and
+// emphasis. The state is 1 inside a fenced code block.
+func highlightMarkdown(l *language, line string, st hlState) ([]span, hlState) {
+	if strings.HasPrefix(strings.TrimSpace(line), "```") {
+		return []span{{line, tokCode}}, 1 - st
+	}
+	if st > 0 {
+		return []span{{line, tokCode}}, st
+	}
+	if markdownHeadingRe.MatchString(line) {
+		return []span{{line, tokHeading}}, st
+	}
+
+	b := spanBuilder{}
+	i := 0
+	for _, m := range markdownInlineRe.FindAllStringIndex(line, -1) {
+		b.add(line[i:m[0]], tokPlain)
+		kind := tokEmphasis
+		if line[m[0]] == '`' {
+			kind = tokCode
+		}
+		b.add(line[m[0]:m[1]], kind)
+		i = m[1]
+	}
+	b.add(line[i:], tokPlain)
+	return b, st
+}
+
+// highlightDiff highlights the content of each line of a diff. Lines outside
+// hunks are nil. The state of the old and new versions of the file are
+// tracked separately, and start over at each hunk.
+func highlightDiff(l *language, diff []string) [][]span {
+	lines := make([][]span, len(diff))
+	var oldState, newState hlState
+	inHunk := false
+
+	for i, line := range diff {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			oldState, newState = 0, 0
+			inHunk = true
+			continue
+		case strings.HasPrefix(line, "diff "):
+			inHunk = false
+			continue
+		case !inHunk || line == "" || line[0] == '\\':
+			continue
+		}
+
+		text := line[1:]
+		switch line[0] {
+		case '-':
+			lines[i], oldState = l.highlight(text, oldState)
+		case '+':
+			lines[i], newState = l.highlight(text, newState)
+		default:
+			lines[i], newState = l.highlight(text, newState)
+			_, oldState = l.highlight(text, oldState)
+		}
+	}
+
+	return lines
+}
+
+// renderSpans renders the content of a diff line. Plain text uses the base
+// style, and the other spans use their token styles. All spans except
+// whitespace errors and search matches get the background bg, if it isn't
+// nil.
+func renderSpans(spans []span, base lipgloss.Style, bg lipgloss.TerminalColor) string {
+	var b strings.Builder
+	for _, s := range spans {
+		style := base
+		if s.kind != tokPlain {
+			style = tokenStyles[s.kind]
+		}
+		if bg != nil && s.kind != tokWhitespaceError && s.kind != tokMatch {
+			style = style.Copy()
+			style.Background(bg)
+		}
+		b.WriteString(style.Render(s.text))
+	}
+	return b.String()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// lineNumbers holds the old and new line numbers of a diff line. A number is
+// 0 if the line isn't in that version of the file.
+type lineNumbers struct {
+	old int
+	new int
+}
+
+// added returns true for a line that's only in the new version
+func (n lineNumbers) added() bool {
+	return n.new != 0 && n.old == 0
+}
+
+// removed returns true for a line that's only in the old version
+func (n lineNumbers) removed() bool {
+	return n.old != 0 && n.new == 0
+}
+
+// numberDiffLines computes the line numbers of each line of a patch from its
+// hunk headers
+func numberDiffLines(diff []string) []lineNumbers {
+	nums := make([]lineNumbers, len(diff))
+	oldLine, newLine := 0, 0
+	inHunk := false
+
+	for i, line := range diff {
+		if match := hunkHeaderRe.FindStringSubmatch(line); match != nil {
+			oldLine, _ = strconv.Atoi(match[1])
+			newLine, _ = strconv.Atoi(match[2])
+			inHunk = true
+			continue
+		}
+
+		if !inHunk || strings.HasPrefix(line, "\\") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "diff ") {
+			inHunk = false
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "-"):
+			nums[i].old = oldLine
+			oldLine++
+		case strings.HasPrefix(line, "+"):
+			nums[i].new = newLine
+			newLine++
+		default:
+			nums[i].old = oldLine
+			nums[i].new = newLine
+			oldLine++
+			newLine++
+		}
+	}
+
+	return nums
+}
@@ -1,5 +1,7 @@
 package main
 
+import "regexp"
+
 type listModel struct {
 	viewModel
 	count      int
@@ -8,6 +10,11 @@ type listModel struct {
 	marked     int
 	cursor     int
 	scrollLock bool
+	// query is the last search, whose matches are highlighted, and found
+	// holds the indexes of the matching items, which views update along with
+	// their contents
+	query *regexp.Regexp
+	found []int
 }
 
 type listView interface {
@@ -20,8 +27,8 @@ type listView interface {
 	setCursor(int)
 	scrollToTop()
 	scrollToBottom()
-	findNext(string)
-	findPrev(string)
+	findNext(*regexp.Regexp)
+	findPrev(*regexp.Regexp)
 	getCount() int
 	getEnd() int
 	getCursor() int
@@ -144,6 +151,13 @@ func (m *listModel) scrollToTop() {
 	}
 }
 
+// scrollTo scrolls the list so that index is at the top of the view, or as
+// close to the top as possible
+func (m *listModel) scrollTo(index int) {
+	m.start = max(min(index, m.count-m.height), 0)
+	m.end = min(m.start+m.height, m.count)
+}
+
 func (m *listModel) scrollBy(amount int) {
 	if amount == 0 {
 		return
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
@@ -12,9 +15,13 @@ import (
 
 type watcherMessage struct {
 	event string
+	op    string
 	path  string
 }
 
+// changeFadeMessage is sent when diff change markers may have expired
+type changeFadeMessage struct{}
+
 type chord struct {
 	key       string
 	startTime time.Time
@@ -46,12 +53,46 @@ type appModel struct {
 
 	searching bool
 	query     string
+	// searchRe is the compiled query of the last search
+	searchRe *regexp.Regexp
+
+	// prompt is shown in the status bar while reading a line of input for
+	// inputAction
+	prompt      string
+	input       string
+	inputAction string
 
 	chord chord
 
-	commits commitsModel
-	stats   statsModel
-	diff    diffModel
+	// the HEAD commit when de was started
+	head string
+
+	// live update controls
+	paused     bool
+	pending    map[string]bool
+	lastChange string
+	follow     bool
+
+	commits   commitsModel
+	stats     statsModel
+	diff      diffModel
+	activity  activityModel
+	interdiff interdiffModel
+	rangeDiff rangeDiffModel
+	patch     patchModel
+	comments  commentsModel
+	options   optionsModel
+	search    searchModel
+	// rangeSearch is true if n and N in the diff view step through the
+	// results of a range-wide search rather than the current file
+	rangeSearch bool
+	// savedStats is the stats view as it was before showFile opened a file
+	// from the activity log, restored when the history is popped back to
+	// savedDepth
+	savedStats *statsModel
+	savedDepth int
+	// finder is the fuzzy finder popup of the commits and stats views
+	finder fuzzyModel
 
 	status string
 }
@@ -72,6 +113,20 @@ func (m *appModel) currentView() listView {
 		return &m.stats
 	case m.diff.name():
 		return &m.diff
+	case m.activity.name():
+		return &m.activity
+	case m.interdiff.name():
+		return &m.interdiff
+	case m.rangeDiff.name():
+		return &m.rangeDiff
+	case m.patch.name():
+		return &m.patch
+	case m.comments.name():
+		return &m.comments
+	case m.options.name():
+		return &m.options
+	case m.search.name():
+		return &m.search
 	}
 	return nil
 }
@@ -89,32 +144,488 @@ func (m *appModel) pushView(view string) {
 
 func (m *appModel) popView() {
 	m.history = m.history[:len(m.history)-1]
+	if m.savedStats != nil && len(m.history) <= m.savedDepth {
+		m.stats = *m.savedStats
+		m.savedStats = nil
+		// the saved stats missed any live updates
+		m.stats.refresh()
+		m.stats.setSize(m.width, m.height-1)
+	}
 }
 
 func (m appModel) getStatus() string {
-	if m.searching {
+	if m.status != "" {
+		return m.status
+	}
+	if m.finder.active {
+		return m.finder.getFuzzyStr()
+	} else if m.searching {
 		return fmt.Sprintf("search: %s", m.query)
+	} else if m.prompt != "" {
+		return fmt.Sprintf("%s: %s", m.prompt, m.input)
 	} else {
 		switch m.currentView().name() {
 		case m.commits.name():
-			return m.commits.getRangeStr()
+			return m.commits.getRangeStr() + m.getMatchStr()
 		case m.stats.name():
-			return m.stats.getCommitsStr()
+			return m.stats.getCommitsStr() + m.stats.getViewStr() + m.stats.getReviewStr() + m.getMatchStr()
 		case m.diff.name():
-			r := m.stats.getCommitsStr()
-			path := m.stats.stat(m.stats.cursor).Path
-			return fmt.Sprintf("%s: %s", r, path)
+			r := m.stats.getCommitsStr() + m.stats.getViewStr()
+			status := fmt.Sprintf("%s: %s", r, m.diff.path)
+			if hunk := m.diff.getHunkStr(); hunk != "" {
+				status += " (" + hunk + ")"
+			}
+			return status + m.getMatchStr()
+		case m.activity.name():
+			return fmt.Sprintf("activity since %s", trunc(m.activityStart(), 8))
+		case m.interdiff.name():
+			return fmt.Sprintf(
+				"snapshot %s (%s)",
+				m.interdiff.snapshot.Name,
+				m.interdiff.snapshot.Time.Format("2006-01-02 15:04"),
+			)
+		case m.rangeDiff.name():
+			return m.rangeDiff.getRangeStr()
+		case m.patch.name():
+			return m.patch.title
+		case m.comments.name():
+			return fmt.Sprintf("comments on %s", m.stats.getCommitsStr())
+		case m.options.name():
+			return "diff options"
+		case m.search.name():
+			return m.search.getSearchStr()
 		}
 	}
 
 	return ""
 }
 
+// applyChanges updates the views for a set of changed paths. The last path is
+// treated as the most recently modified file.
+func (m *appModel) applyChanges(paths []string) tea.Cmd {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	m.stats.refresh()
+	m.invalidateMoves(paths)
+
+	inDiff := m.currentViewName() == m.diff.name()
+
+	if m.follow {
+		latest := paths[len(paths)-1]
+		if index := m.stats.rowOf(latest); index >= 0 {
+			m.stats.setCursor(index)
+			if inDiff && m.diff.path != latest {
+				m.diff.setDiffStat(m.stats.selected())
+				return nil
+			}
+		}
+	}
+
+	if inDiff {
+		for _, path := range paths {
+			if m.diff.path != path {
+				continue
+			}
+			if m.diff.liveRefresh() && m.diff.changeFade > 0 {
+				return tea.Tick(m.diff.changeFade, func(time.Time) tea.Msg {
+					return changeFadeMessage{}
+				})
+			}
+			break
+		}
+	}
+
+	return nil
+}
+
+// invalidateMoves makes the moved blocks be detected again if a changed path
+// had changes in their range, or has now. Ranges that don't end at the
+// worktree aren't affected by changes.
+func (m *appModel) invalidateMoves(paths []string) {
+	if m.diff.commits.end != "" {
+		return
+	}
+	inStats := map[string]bool{}
+	if m.stats.commits == m.diff.commits {
+		for _, s := range m.stats.stats {
+			inStats[s.Path] = true
+		}
+	}
+	for _, path := range paths {
+		if m.diff.moves.hasPath(path) || inStats[path] {
+			m.diff.invalidateMoves()
+			return
+		}
+	}
+}
+
+// togglePause pauses or resumes watcher-driven updates. Changes that arrived
+// while paused are applied on resume.
+func (m *appModel) togglePause() tea.Cmd {
+	m.paused = !m.paused
+	if m.paused {
+		m.pending = map[string]bool{}
+		return nil
+	}
+
+	var paths []string
+	for path := range m.pending {
+		if path != m.lastChange {
+			paths = append(paths, path)
+		}
+	}
+	if m.pending[m.lastChange] {
+		paths = append(paths, m.lastChange)
+	}
+	m.pending = nil
+
+	return m.applyChanges(paths)
+}
+
+// activityStart returns the commit that activity is measured against: the
+// start of the current stats range if it ends at the worktree, otherwise HEAD
+func (m appModel) activityStart() string {
+	if m.stats.commits.start != "" && m.stats.commits.end == "" {
+		return m.stats.commits.start
+	}
+	return m.head
+}
+
+// showFile opens the diff view for path in the range starting at start and
+// ending at the worktree. The diff view navigates through the stats view, so
+// the stats view is switched to that range and restored when the diff view is
+// closed.
+func (m *appModel) showFile(start, path string) {
+	saved := m.stats
+	if m.stats.commits.start != start || m.stats.commits.end != "" {
+		m.stats.setDiff(commitRange{start: start})
+		m.stats.setSize(m.width, m.height-1)
+	}
+
+	index := m.stats.rowOf(path)
+	if index < 0 {
+		if m.stats.hasPath(path) {
+			m.status = fmt.Sprintf("%s is hidden in the stats view", path)
+		} else {
+			m.status = fmt.Sprintf("%s has no changes", path)
+		}
+		m.stats = saved
+		return
+	}
+	m.stats.setCursor(index)
+
+	if m.savedStats == nil {
+		m.savedStats = &saved
+		m.savedDepth = len(m.history)
+	}
+	m.diff.setDiff(m.stats.commits, m.stats.selected())
+	m.diff.setSize(m.width, m.height-1)
+	m.pushView("diff")
+}
+
+// startInput prompts for a line of input, which is passed to submitInput
+// along with action when the user presses enter
+func (m *appModel) startInput(prompt, action string) {
+	m.prompt = prompt
+	m.input = ""
+	m.inputAction = action
+}
+
+func (m *appModel) submitInput() {
+	input := strings.TrimSpace(m.input)
+	action := m.inputAction
+	m.prompt = ""
+	m.inputAction = ""
+	m.diff.commenting = false
+
+	switch action {
+	case "comment":
+		if input == "" {
+			return
+		}
+		if err := m.diff.addComment(input); err != nil {
+			m.status = fmt.Sprintf("can't add comment: %v", err)
+		}
+
+	case "snapshot":
+		if err := takeSnapshot(input, m.stats.commits.start, m.diff.opts); err != nil {
+			m.status = fmt.Sprintf("snapshot failed: %v", err)
+		} else {
+			m.status = fmt.Sprintf("saved snapshot %s", input)
+		}
+
+	case "filter":
+		if err := m.stats.setFilter(input); err != nil {
+			m.status = fmt.Sprintf("bad filter: %v", err)
+		}
+
+	case "compare":
+		s, err := loadSnapshot(input)
+		if err != nil {
+			m.status = fmt.Sprintf("can't load snapshot: %v", err)
+			return
+		}
+		m.interdiff.setSnapshot(s, m.diff.opts)
+		m.interdiff.setSize(m.width, m.height-1)
+		m.pushView("interdiff")
+
+	case "rangesearch":
+		if input == "" {
+			return
+		}
+		re, err := compileQuery(input)
+		if err != nil {
+			m.status = fmt.Sprintf("bad search: %v", err)
+			return
+		}
+		results := searchRange(m.stats.commits, m.stats.sortedStats(), m.diff.opts, re)
+		m.search.setResults(input, m.stats.commits, results)
+		m.search.query = re
+		m.search.setSize(m.width, m.height-1)
+		m.rangeSearch = true
+		m.pushView("search")
+	}
+}
+
+// submitSearch searches the current view for the query, and highlights its
+// matches in the commits, stats and diff views. An empty query clears the
+// highlights.
+func (m *appModel) submitSearch() {
+	var re *regexp.Regexp
+	if m.query != "" {
+		var err error
+		if re, err = compileQuery(m.query); err != nil {
+			m.status = fmt.Sprintf("bad search: %v", err)
+			return
+		}
+	}
+
+	m.searchRe = re
+	m.commits.setQuery(re)
+	m.stats.setQuery(re)
+	m.diff.setQuery(re)
+	if re != nil {
+		m.currentView().findNext(re)
+	}
+}
+
+// getMatchStr describes the position of the cursor among the search matches
+// in the commits, stats and diff views
+func (m appModel) getMatchStr() string {
+	if m.searchRe == nil {
+		return ""
+	}
+	switch m.currentViewName() {
+	case m.commits.name():
+		return " " + getMatchStr(m.commits.found, m.commits.cursor)
+	case m.stats.name():
+		return " " + getMatchStr(m.stats.found, m.stats.cursor)
+	case m.diff.name():
+		return " " + getMatchStr(m.diff.found, m.diff.searchPos())
+	}
+	return ""
+}
+
+// showResult opens the diff view at a range search result
+func (m *appModel) showResult(result int) {
+	r := m.search.results[result]
+	m.search.setCurrent(result)
+	if index := m.stats.rowOf(r.stat.Path); index >= 0 {
+		m.stats.setCursor(index)
+	}
+	if m.diff.path != r.stat.Path || m.diff.commits != m.search.commits {
+		m.diff.setDiff(m.search.commits, r.stat)
+	}
+	m.diff.setSize(m.width, m.height-1)
+	if m.currentViewName() != m.diff.name() {
+		m.pushView("diff")
+	}
+	m.diff.scrollToPatchLine(r.line)
+	m.search.currentTop = m.diff.start
+}
+
+// stepResult shows the next or previous range search result, continuing into
+// other files. It steps from the result last shown if the diff view hasn't
+// scrolled since, as a result near the end of a file can't be scrolled to
+// the top, and otherwise from the top of the diff view.
+func (m *appModel) stepResult(forward bool) {
+	var next int
+	if c := m.search.current; c >= 0 && m.search.results[c].stat.Path == m.diff.path &&
+		m.diff.start == m.search.currentTop {
+		next = c - 1
+		if forward {
+			next = c + 1
+		}
+		if next >= len(m.search.results) {
+			next = -1
+		}
+	} else {
+		line := m.diff.topLine() - patchOffset(m.diff.diff)
+		next = m.search.step(m.diff.path, line, forward)
+	}
+	if next < 0 {
+		m.status = "no more matches"
+		return
+	}
+	m.showResult(next)
+}
+
+func (m *appModel) showRangeDiff(args []string) {
+	if err := m.rangeDiff.setRange(args); err != nil {
+		m.status = fmt.Sprintf("range-diff failed: %v", err)
+		return
+	}
+	m.rangeDiff.setSize(m.width, m.height-1)
+	m.pushView("rangediff")
+}
+
+func (m *appModel) showComments() {
+	m.comments.setComments(m.diff.comments.forRange(m.stats.commits))
+	m.comments.setSize(m.width, m.height-1)
+	if m.currentViewName() != m.comments.name() {
+		m.pushView("comments")
+	}
+}
+
+// showComment opens the diff view at the line a comment is attached to
+func (m *appModel) showComment(c comment) {
+	m.stats.expandTo(c.Path)
+	index := m.stats.rowOf(c.Path)
+	if index < 0 {
+		m.status = fmt.Sprintf("%s isn't in the current range", c.Path)
+		return
+	}
+	m.stats.setCursor(index)
+	m.diff.setDiff(m.stats.commits, m.stats.selected())
+	m.diff.setSize(m.width, m.height-1)
+	m.pushView("diff")
+
+	line := resolveComment(c, m.diff.diff, numberDiffLines(m.diff.diff))
+	if line < 0 {
+		m.status = "the commented line is no longer in the diff"
+		return
+	}
+	m.diff.scrollToLine(line)
+}
+
+func (m *appModel) exportComments() {
+	comments := m.diff.comments.forRange(m.stats.commits)
+	if len(comments) == 0 {
+		m.status = "no comments to export"
+		return
+	}
+	file, err := exportComments(comments, m.stats.getCommitsStr())
+	if err != nil {
+		m.status = fmt.Sprintf("export failed: %v", err)
+	} else {
+		m.status = "exported comments to " + file
+	}
+}
+
+// openFuzzy opens the fuzzy finder over the rows of the commits or stats view
+func (m *appModel) openFuzzy() {
+	switch m.currentViewName() {
+	case m.commits.name():
+		m.finder.open(m.commits.name(), m.commits.fuzzyItems())
+	case m.stats.name():
+		m.finder.open(m.stats.name(), m.stats.fuzzyItems())
+	default:
+		m.status = "the fuzzy finder works in the commits and stats views"
+	}
+}
+
+// selectFuzzy closes the fuzzy finder and moves the cursor of its view to the
+// selected row. Stats rows are looked up again by path, since the stats may
+// have been refreshed while the finder was open.
+func (m *appModel) selectFuzzy() {
+	item, ok := m.finder.selected()
+	view := m.finder.view
+	m.finder.close()
+	if !ok {
+		return
+	}
+	switch view {
+	case m.commits.name():
+		row := m.commits.rowOf(item.commit)
+		if row < 0 {
+			m.status = fmt.Sprintf("%s is no longer in the list", trunc(item.commit, 8))
+			return
+		}
+		m.commits.setCursor(row)
+	case m.stats.name():
+		row := m.stats.rowOf(item.path)
+		if row < 0 {
+			m.status = fmt.Sprintf("%s is no longer in the range", item.path)
+			return
+		}
+		m.stats.setCursor(row)
+	}
+}
+
+// jumpToMoved scrolls from a moved line at the top of the diff view to its
+// counterpart, which may be in another file of the range
+func (m *appModel) jumpToMoved() {
+	l, ok := m.diff.movedAt()
+	if !ok {
+		m.status = "not a moved line"
+		return
+	}
+	if l.path != m.diff.path {
+		m.stats.expandTo(l.path)
+		index := m.stats.rowOf(l.path)
+		if index < 0 {
+			m.status = fmt.Sprintf("%s is hidden in the stats view", l.path)
+			return
+		}
+		m.stats.setCursor(index)
+		m.diff.setDiffStat(m.stats.selected())
+	}
+	if !m.diff.scrollToLineNums(l.counterpart()) {
+		m.status = fmt.Sprintf("line %d isn't in the diff of %s", l.lineNum, l.path)
+	}
+}
+
+// changeOption changes the diff option at the cursor in the options menu
+func (m *appModel) changeOption() {
+	key := m.options.selected().key
+	changeOption(&m.diff.opts, key)
+	m.options.opts = m.diff.opts
+	if key == "renames" || key == "copies" {
+		// the file's paths change when renames are detected differently
+		m.stats.refresh()
+		if m.stats.isFileSelected() {
+			m.diff.setDiffStat(m.stats.selected())
+			return
+		}
+	}
+	m.diff.refresh()
+}
+
+// isWorktreeRange returns true if the stats view is showing changes between
+// a commit and the worktree
+func (m appModel) isWorktreeRange() bool {
+	return m.stats.commits.start != "" && m.stats.commits.end == ""
+}
+
 func (m appModel) Init() tea.Cmd {
 	return m.watcherLoading.Tick
 }
 
 func (m appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := m.update(msg)
+	m = model.(appModel)
+	// the diff view shows moved blocks once they're detected
+	if m.currentViewName() == m.diff.name() {
+		if movesCmd := m.diff.loadMoves(); movesCmd != nil {
+			return m, tea.Batch(cmd, movesCmd)
+		}
+	}
+	return m, cmd
+}
+
+func (m appModel) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -125,7 +636,29 @@ func (m appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case tea.KeyMsg:
-		if m.searching {
+		m.status = ""
+		if m.finder.active {
+			switch msg.String() {
+			case "esc":
+				m.finder.close()
+			case "backspace":
+				if q := []rune(m.finder.query); len(q) > 0 {
+					m.finder.setQuery(string(q[:len(q)-1]))
+				}
+			case "down", "ctrl+n":
+				m.finder.next()
+			case "up", "ctrl+p":
+				m.finder.prev()
+			case "enter":
+				m.selectFuzzy()
+			case "ctrl+c":
+				return m, tea.Quit
+			default:
+				if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+					m.finder.setQuery(m.finder.query + msg.String())
+				}
+			}
+		} else if m.searching {
 			switch msg.String() {
 			case "esc":
 				m.searching = false
@@ -135,7 +668,7 @@ func (m appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			case "enter":
 				m.searching = false
-				m.currentView().findNext(m.query)
+				m.submitSearch()
 			case "ctrl+c":
 				return m, tea.Quit
 			default:
@@ -143,18 +676,46 @@ func (m appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.query += msg.String()
 				}
 			}
+		} else if m.prompt != "" {
+			switch msg.String() {
+			case "esc":
+				m.prompt = ""
+				m.diff.commenting = false
+			case "backspace":
+				if len(m.input) > 0 {
+					m.input = m.input[0 : len(m.input)-1]
+				}
+			case "enter":
+				m.submitInput()
+			case "ctrl+c":
+				return m, tea.Quit
+			default:
+				if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+					m.input += msg.String()
+				}
+			}
 		} else {
 			switch msg.String() {
 			case "ctrl+c":
 				return m, tea.Quit
 
 			case " ":
-				if c := m.currentView(); c != nil {
+				if m.currentViewName() == m.options.name() {
+					m.changeOption()
+				} else if m.currentViewName() == m.stats.name() {
+					if err := m.stats.toggleReviewed(); err != nil {
+						m.status = fmt.Sprintf("can't save review marks: %v", err)
+					}
+				} else if c := m.currentView(); c != nil {
 					c.mark()
 				}
 
+			case "ctrl+p":
+				m.openFuzzy()
+
 			case "/":
 				m.searching = true
+				m.rangeSearch = false
 				m.query = ""
 
 			case "1":
@@ -184,9 +745,9 @@ func (m appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					c.nextItem()
 				}
 
-			case "J":
+			case "J", "}":
 				if m.currentView().name() == m.diff.name() {
-					m.stats.nextItem()
+					m.stats.nextFile()
 					m.diff.setDiffStat(m.stats.selected())
 				}
 
@@ -195,32 +756,75 @@ func (m appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					c.prevItem()
 				}
 
-			case "K":
+			case "K", "{":
 				if m.currentView().name() == m.diff.name() {
-					m.stats.prevItem()
+					m.stats.prevFile()
 					m.diff.setDiffStat(m.stats.selected())
 				}
 
 			case "n":
-				if len(m.query) > 0 {
-					m.currentView().findNext(m.query)
+				if m.rangeSearch && m.currentViewName() == m.diff.name() && m.diff.commits == m.search.commits {
+					m.stepResult(true)
+				} else if m.searchRe != nil {
+					m.currentView().findNext(m.searchRe)
 				}
 
 			case "N":
-				if len(m.query) > 0 {
-					m.currentView().findPrev(m.query)
+				if m.rangeSearch && m.currentViewName() == m.diff.name() && m.diff.commits == m.search.commits {
+					m.stepResult(false)
+				} else if m.searchRe != nil {
+					m.currentView().findPrev(m.searchRe)
+				}
+
+			case "?":
+				if m.currentViewName() == m.stats.name() || m.currentViewName() == m.diff.name() {
+					m.startInput("search range", "rangesearch")
 				}
 
 			case "enter":
-				if m.currentViewName() == m.commits.name() {
+				if m.currentViewName() == m.options.name() {
+					m.changeOption()
+				} else if m.currentViewName() == m.search.name() {
+					if result := m.search.selected(); result >= 0 {
+						m.showResult(result)
+					}
+				} else if m.currentViewName() == m.comments.name() {
+					if m.comments.cursor >= 0 {
+						m.showComment(m.comments.selected())
+					}
+				} else if m.currentViewName() == m.rangeDiff.name() {
+					if m.rangeDiff.cursor >= 0 {
+						p := m.rangeDiff.selected()
+						title := fmt.Sprintf("%s: %s", m.rangeDiff.getRangeStr(), p.subject)
+						m.patch.setPatch(title, m.rangeDiff.pairPatch(p, m.diff.opts))
+						m.patch.setSize(m.width, m.height-1)
+						m.pushView("patch")
+					}
+				} else if m.currentViewName() == m.interdiff.name() {
+					if m.interdiff.cursor >= 0 {
+						f := m.interdiff.selected()
+						title := fmt.Sprintf("snapshot %s: %s", m.interdiff.snapshot.Name, f.path)
+						m.patch.setPatch(title, f.lines)
+						m.patch.setSize(m.width, m.height-1)
+						m.pushView("patch")
+					}
+				} else if m.currentViewName() == m.activity.name() {
+					if m.activity.cursor >= 0 {
+						m.showFile(m.activityStart(), m.activity.selected().path)
+					}
+				} else if m.currentViewName() == m.commits.name() {
 					m.stats.setDiff(m.commits.getRange())
 					m.stats.setSize(m.width, m.height-1)
 					m.pushView("stats")
+				} else if m.currentViewName() == m.diff.name() {
+					m.jumpToMoved()
 				} else if m.currentViewName() == m.stats.name() {
-					if m.stats.cursor >= 0 {
+					if m.stats.isFileSelected() {
 						m.diff.setDiff(m.stats.commits, m.stats.selected())
 						m.diff.setSize(m.width, m.height-1)
 						m.pushView("diff")
+					} else if m.stats.cursor >= 0 {
+						m.stats.toggleCollapsed()
 					}
 				}
 
@@ -243,6 +847,216 @@ func (m appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.diff.opts.ignoreWhitespace = !m.diff.opts.ignoreWhitespace
 					m.diff.refresh()
 				}
+
+			case "e":
+				if m.currentViewName() == m.diff.name() && m.diff.stat.Binary {
+					paths, err := extractBinary(m.diff.stat)
+					if err != nil {
+						m.status = fmt.Sprintf("extract failed: %v", err)
+					} else {
+						m.status = "extracted to " + strings.Join(paths, ", ")
+					}
+				}
+
+			case "+", "=":
+				if m.currentViewName() == m.diff.name() {
+					m.diff.setContext(1)
+				}
+
+			case "-":
+				if m.currentViewName() == m.diff.name() {
+					m.diff.setContext(-1)
+				}
+
+			case "U":
+				if m.currentViewName() == m.diff.name() {
+					m.diff.nextContextMode()
+				}
+
+			case "<", ">":
+				if m.currentViewName() == m.diff.name() {
+					if err := m.diff.expandHunk(msg.String() == "<"); err != nil {
+						m.status = err.Error()
+					}
+				}
+
+			case "O":
+				if m.currentViewName() == m.diff.name() {
+					m.options.opts = m.diff.opts
+					m.options.setSize(m.width, m.height-1)
+					m.pushView("options")
+				}
+
+			case "v":
+				if m.currentViewName() == m.diff.name() {
+					m.diff.toggleWhitespace()
+				}
+
+			case "#":
+				if m.currentViewName() == m.diff.name() {
+					m.diff.toggleLineNums()
+				}
+
+			case "]":
+				if m.currentViewName() == m.diff.name() {
+					m.diff.nextHunk()
+				}
+
+			case "[":
+				if m.currentViewName() == m.diff.name() {
+					m.diff.prevHunk()
+				}
+
+			case "c":
+				if m.currentViewName() == m.diff.name() {
+					m.diff.commenting = true
+					m.startInput("comment", "comment")
+				}
+
+			case "d":
+				if m.currentViewName() == m.diff.name() {
+					if err := m.diff.removeComment(); err != nil {
+						m.status = err.Error()
+					}
+				} else if m.currentViewName() == m.comments.name() {
+					if m.comments.cursor >= 0 {
+						if err := m.diff.comments.remove(m.comments.selected()); err != nil {
+							m.status = fmt.Sprintf("can't remove comment: %v", err)
+						}
+						m.diff.buildRows()
+						m.showComments()
+					}
+				}
+
+			case "C":
+				switch m.currentViewName() {
+				case m.stats.name(), m.diff.name():
+					m.showComments()
+				}
+
+			case "E":
+				switch m.currentViewName() {
+				case m.stats.name(), m.diff.name(), m.comments.name():
+					m.exportComments()
+				}
+
+			case ".":
+				if m.currentViewName() == m.diff.name() {
+					m.diff.nextChange()
+				}
+
+			case "x":
+				if m.currentViewName() == m.diff.name() {
+					m.diff.clearChanges()
+				}
+
+			case "p":
+				cmd := m.togglePause()
+				return m, cmd
+
+			case "F":
+				m.follow = !m.follow
+
+			case "S":
+				if m.currentViewName() == m.stats.name() {
+					if m.isWorktreeRange() {
+						m.startInput("snapshot name", "snapshot")
+					} else {
+						m.status = "snapshots can only be taken of worktree diffs"
+					}
+				}
+
+			case "I":
+				if m.currentViewName() == m.stats.name() {
+					m.startInput("compare to snapshot (empty for latest)", "compare")
+				}
+
+			case "t":
+				if m.currentViewName() == m.stats.name() {
+					m.stats.toggleTree()
+				}
+
+			case "o":
+				if m.currentViewName() == m.stats.name() {
+					m.stats.nextSort()
+				}
+
+			case "b":
+				if m.currentViewName() == m.stats.name() {
+					m.stats.showBars = !m.stats.showBars
+				}
+
+			case "H":
+				if m.currentViewName() == m.stats.name() {
+					m.stats.toggleHideReviewed()
+				}
+
+			case "f":
+				if m.currentViewName() == m.stats.name() {
+					m.startInput("filter", "filter")
+					m.input = m.stats.filter.text
+				}
+
+			case "h":
+				if m.currentViewName() == m.stats.name() {
+					m.stats.collapse()
+				} else if m.currentViewName() == m.diff.name() {
+					if m.chord.getKey() == "z" {
+						m.diff.scrollRight(-m.diff.contentWidth() / 2)
+					} else {
+						m.diff.scrollRight(-hscrollStep)
+					}
+				}
+
+			case "l":
+				if m.currentViewName() == m.stats.name() {
+					m.stats.expand()
+				} else if m.currentViewName() == m.diff.name() {
+					if m.chord.getKey() == "z" {
+						m.diff.scrollRight(m.diff.contentWidth() / 2)
+					} else {
+						m.diff.scrollRight(hscrollStep)
+					}
+				}
+
+			case "z":
+				m.chord.start("z")
+
+			case "a":
+				if m.currentViewName() == m.diff.name() && m.chord.getKey() == "z" {
+					if err := m.diff.toggleFold(); err != nil {
+						m.status = err.Error()
+					}
+				}
+
+			case "M":
+				if m.currentViewName() == m.diff.name() && m.chord.getKey() == "z" {
+					m.diff.foldAll()
+				}
+
+			case "W":
+				if m.currentViewName() == m.diff.name() {
+					m.diff.toggleWrap()
+				}
+
+			case "R":
+				if m.currentViewName() == m.diff.name() && m.chord.getKey() == "z" {
+					m.diff.unfoldAll()
+				} else if m.currentViewName() == m.commits.name() {
+					if m.commits.marked < 0 {
+						m.status = "mark the old version of the branch first"
+					} else {
+						oldCommit := m.commits.commit(m.commits.marked).Commit
+						newCommit := m.commits.selected().Commit
+						m.showRangeDiff([]string{oldCommit + "..." + newCommit})
+					}
+				}
+
+			case "A":
+				if m.currentViewName() != m.activity.name() {
+					m.activity.setSize(m.width, m.height-1)
+					m.pushView("activity")
+				}
 			}
 		}
 
@@ -250,12 +1064,30 @@ func (m appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.event {
 		case "ready":
 			m.watcherReady = true
+			m.status = msg.path
 		case "filechange":
-			if m.currentViewName() == m.diff.name() && m.diff.path == msg.path {
-				m.diff.refresh()
+			m.activity.record(msg.op, msg.path, m.activityStart())
+			statsCmd := m.activity.loadStats()
+			m.lastChange = msg.path
+			if m.paused {
+				m.pending[msg.path] = true
+				return m, statsCmd
 			}
-			m.stats.refresh()
+			cmd := m.applyChanges([]string{msg.path})
+			return m, tea.Batch(statsCmd, cmd)
+		}
+
+	case movesMessage:
+		m.diff.setMoves(msg)
+
+	case activityStatsMessage:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("loading activity stats failed: %v", msg.err)
 		}
+		return m, m.activity.setStats(msg)
+
+	case changeFadeMessage:
+		m.diff.expireChanges()
 
 	default:
 		var cmd tea.Cmd
@@ -274,14 +1106,27 @@ func (m appModel) View() string {
 	if c := m.currentView(); c != nil {
 		mainSection = c.render()
 	}
+	if m.finder.active {
+		mainSection = m.finder.overlay(mainSection, m.width, m.height-1)
+	}
 
 	statusTwo := ""
 	if !m.watcherReady {
 		statusTwo += m.watcherLoading.View()
 	}
 
-	if m.diff.opts.ignoreWhitespace {
-		statusTwo += "W"
+	statusTwo += getOptionsStr(m.diff.opts)
+
+	statusTwo += m.diff.getContextStr()
+
+	statusTwo += m.diff.getScrollStr()
+
+	if m.paused {
+		statusTwo += fmt.Sprintf("P%d", len(m.pending))
+	}
+
+	if m.follow {
+		statusTwo += "F"
 	}
 
 	statusTwoStyle.Width(len(statusTwo) + 2)
@@ -315,9 +1160,62 @@ func (m appModel) View() string {
 }
 
 func main() {
+	var watchOpts watchOptions
+	var pathspecs string
+	var changeFade time.Duration
+	var rangeDiff string
+	var tabWidth int
+	watchOpts.strategy = "walk"
+	flag.Func(
+		"watch",
+		"how to find directories to watch: walk or tracked (default walk)",
+		func(value string) error {
+			if value != "walk" && value != "tracked" {
+				return fmt.Errorf("must be walk or tracked")
+			}
+			watchOpts.strategy = value
+			return nil
+		},
+	)
+	flag.StringVar(
+		&pathspecs,
+		"watch-path",
+		"",
+		"comma-separated pathspecs to limit the tracked watch strategy",
+	)
+	flag.BoolVar(
+		&watchOpts.sparse,
+		"watch-sparse",
+		false,
+		"limit the tracked watch strategy to the sparse-checkout cone",
+	)
+	flag.DurationVar(
+		&changeFade,
+		"change-fade",
+		30*time.Second,
+		"how long live changes stay highlighted in the diff view (0 to keep until acknowledged)",
+	)
+	flag.StringVar(
+		&rangeDiff,
+		"range-diff",
+		"",
+		"start in a range-diff view comparing two versions of a branch (old...new)",
+	)
+	flag.IntVar(
+		&tabWidth,
+		"tab-width",
+		0,
+		"tab width in the diff view (0 to use the file's .editorconfig or gitattributes)",
+	)
+	flag.Parse()
+
+	if pathspecs != "" {
+		watchOpts.pathspecs = strings.Split(pathspecs, ",")
+	}
+
 	repoPath := "."
-	if len(os.Args) > 1 {
-		repoPath = os.Args[1]
+	if flag.NArg() > 0 {
+		repoPath = flag.Arg(0)
 	}
 	os.Chdir(repoPath)
 
@@ -329,20 +1227,35 @@ func main() {
 		commits:        newCommitsModel(),
 		stats:          newStatsModel(),
 		diff:           newDiffModel(),
+		activity:       newActivityModel(),
+		interdiff:      newInterdiffModel(),
+		rangeDiff:      newRangeDiffModel(),
+		patch:          newPatchModel(),
+		comments:       newCommentsModel(),
+		options:        newOptionsModel(),
+		search:         newSearchModel(),
+		head:           gitRevParse("HEAD"),
 		status:         "",
 		watcherLoading: s,
 	}
+	m.diff.changeFade = changeFade
+	m.diff.opts.tabWidth = tabWidth
+	m.diff.comments = loadComments()
+
+	if rangeDiff != "" {
+		m.showRangeDiff(strings.Fields(rangeDiff))
+	}
 
 	p := tea.NewProgram(m, tea.WithAltScreen())
 
 	onNotify := func(event, path string) {
 		if event == "ready" {
-			p.Send(watcherMessage{event: "ready", path: ""})
+			p.Send(watcherMessage{event: "ready", path: path})
 		} else {
-			p.Send(watcherMessage{event: "filechange", path: path})
+			p.Send(watcherMessage{event: "filechange", op: event, path: path})
 		}
 	}
-	watcher := watchRepo(".", onNotify)
+	watcher := watchRepo(".", watchOpts, onNotify)
 	defer watcher.Close()
 
 	if err := p.Start(); err != nil {
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// a block of lines must be at least this long, and contain this many
+// alphanumeric characters, to count as moved. This is the same heuristic git
+// uses for --color-moved, which keeps braces and blank lines from matching.
+const (
+	movedMinLines = 3
+	movedMinAlnum = 20
+)
+
+// moves are detected in a diff without context, whatever the context shown
+const movedContext = 0
+
+// movedLine describes a removed line that was added elsewhere in the range,
+// or an added line that was removed elsewhere
+type movedLine struct {
+	// block numbers the moved blocks, so neighboring blocks can be told apart
+	block int
+	// from is true for the removed side of a move
+	from bool
+	// first is true for the first line of a block
+	first bool
+	// path and lineNum locate the counterpart line, which is on the other
+	// side of the diff
+	path    string
+	lineNum int
+}
+
+// moveMap holds the moved lines of every file in a range, by their line
+// numbers. A removed line only has an old number and an added line only a
+// new one, so each is keyed by lineNumbers with the other number 0.
+type moveMap struct {
+	paths map[string]bool
+	lines map[string]map[lineNumbers]movedLine
+}
+
+// movesMessage carries the moved blocks detected in the background, for the
+// range and options in key
+type movesMessage struct {
+	key   string
+	moves moveMap
+}
+
+type lineLoc struct {
+	path  string
+	index int
+}
+
+// splitPatches splits the patch of a whole range into the patches of each
+// file, in the order they appear. Lines before the first file, such as a
+// commit message, are dropped.
+func splitPatches(diff []string) ([]string, map[string][]string) {
+	var paths []string
+	patches := map[string][]string{}
+
+	path := ""
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			paths = append(paths, path)
+			patches[path] = diff[start:end]
+		}
+	}
+
+	for i, line := range diff {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush(i)
+			start = i
+			if j := strings.LastIndex(line, " b/"); j >= 0 {
+				path = line[j+3:]
+			}
+		} else if start >= 0 && strings.HasPrefix(line, "+++ b/") {
+			path = line[len("+++ b/"):]
+		}
+	}
+	flush(len(diff))
+
+	return paths, patches
+}
+
+func countAlnum(lines []string) int {
+	count := 0
+	for _, line := range lines {
+		for _, r := range line[1:] {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// detectMoves finds blocks of added lines that match blocks of removed lines
+// anywhere in the range. Each added line is matched with the longest run of
+// unused removed lines that starts with the same text.
+func detectMoves(diff []string) moveMap {
+	paths, patches := splitPatches(diff)
+	m := moveMap{paths: map[string]bool{}, lines: map[string]map[lineNumbers]movedLine{}}
+
+	removed := map[string][]lineLoc{}
+	nums := map[string][]lineNumbers{}
+	for _, path := range paths {
+		m.paths[path] = true
+		nums[path] = numberDiffLines(patches[path])
+		for i, line := range patches[path] {
+			if nums[path][i].removed() {
+				removed[line[1:]] = append(removed[line[1:]], lineLoc{path, i})
+			}
+		}
+	}
+
+	used := map[lineLoc]bool{}
+	mark := func(at lineLoc, l movedLine) {
+		if m.lines[at.path] == nil {
+			m.lines[at.path] = map[lineNumbers]movedLine{}
+		}
+		m.lines[at.path][nums[at.path][at.index]] = l
+	}
+
+	block := 0
+	for _, path := range paths {
+		p := patches[path]
+		pn := nums[path]
+		for i := 0; i < len(p); {
+			if !pn[i].added() {
+				i++
+				continue
+			}
+
+			var best lineLoc
+			bestLen := 0
+			for _, loc := range removed[p[i][1:]] {
+				rp, rn := patches[loc.path], nums[loc.path]
+				n := 0
+				for i+n < len(p) && loc.index+n < len(rp) &&
+					pn[i+n].added() && rn[loc.index+n].removed() &&
+					p[i+n][1:] == rp[loc.index+n][1:] &&
+					!used[lineLoc{loc.path, loc.index + n}] {
+					n++
+				}
+				if n > bestLen {
+					best, bestLen = loc, n
+				}
+			}
+
+			if bestLen < movedMinLines || countAlnum(p[i:i+bestLen]) < movedMinAlnum {
+				i++
+				continue
+			}
+
+			for n := 0; n < bestLen; n++ {
+				to := lineLoc{path, i + n}
+				from := lineLoc{best.path, best.index + n}
+				used[from] = true
+				mark(to, movedLine{
+					block:   block,
+					first:   n == 0,
+					path:    from.path,
+					lineNum: nums[from.path][from.index].old,
+				})
+				mark(from, movedLine{
+					block:   block,
+					from:    true,
+					first:   n == 0,
+					path:    to.path,
+					lineNum: nums[to.path][to.index].new,
+				})
+			}
+			block++
+			i += bestLen
+		}
+	}
+
+	return m
+}
+
+// patchOffset returns the index of the first file header in a diff, which
+// follows the commit message in the output of git show
+func patchOffset(diff []string) int {
+	for i, line := range diff {
+		if strings.HasPrefix(line, "diff --git ") {
+			return i
+		}
+	}
+	return 0
+}
+
+// forFile returns the moved lines of a file indexed by line of diff, given
+// the line numbers of the diff lines
+func (m moveMap) forFile(path string, nums []lineNumbers) map[int]movedLine {
+	lines := m.lines[path]
+	if len(lines) == 0 {
+		return nil
+	}
+
+	moved := map[int]movedLine{}
+	for i, n := range nums {
+		if !n.added() && !n.removed() {
+			continue
+		}
+		if l, ok := lines[n]; ok {
+			moved[i] = l
+		}
+	}
+	return moved
+}
+
+// counterpart returns the line numbers of the other side of a moved line
+func (l movedLine) counterpart() lineNumbers {
+	if l.from {
+		return lineNumbers{new: l.lineNum}
+	}
+	return lineNumbers{old: l.lineNum}
+}
+
+// describe returns the annotation shown on the first line of a moved block
+func (l movedLine) describe() string {
+	if l.from {
+		return fmt.Sprintf("→ moved to %s:%d", l.path, l.lineNum)
+	}
+	return fmt.Sprintf("← moved from %s:%d", l.path, l.lineNum)
+}
+
+// getMovesKey returns the key of the moved blocks of the current range, which
+// depend on the options that change which lines are added and removed
+func (m diffModel) getMovesKey() string {
+	opts := m.opts
+	opts.tabWidth, opts.showWhitespace = 0, false
+	opts.context, opts.contextMode = movedContext, ""
+	return fmt.Sprintf("%s %v %v", rangeKey(m.commits), opts, renameArgs())
+}
+
+// loadMoves returns a command that detects the moved blocks in the current
+// range in the background, unless they were already detected or are being
+// detected with the same options
+func (m *diffModel) loadMoves() tea.Cmd {
+	if m.commits.start == "" {
+		return nil
+	}
+	key := m.getMovesKey()
+	if (m.moves != nil && key == m.movesKey) || key == m.movesLoading {
+		return nil
+	}
+	m.movesLoading = key
+
+	c := m.commits
+	opts := m.opts
+	opts.context, opts.contextMode = movedContext, ""
+	return func() tea.Msg {
+		return movesMessage{key, detectMoves(gitDiff(c.start, c.end, "", "", opts))}
+	}
+}
+
+// setMoves shows the moved blocks detected in the background, unless the
+// range or options changed meanwhile
+func (m *diffModel) setMoves(msg movesMessage) {
+	if msg.key == m.movesLoading {
+		m.movesLoading = ""
+	}
+	if msg.key != m.getMovesKey() {
+		return
+	}
+	m.moves = &msg.moves
+	m.movesKey = msg.key
+	m.buildRows()
+}
+
+// invalidateMoves makes the moved blocks be detected again
+func (m *diffModel) invalidateMoves() {
+	m.moves = nil
+	m.movesLoading = ""
+}
+
+// hasPath returns true if path has changes in the range of the moved blocks
+func (m *moveMap) hasPath(path string) bool {
+	return m != nil && m.paths[path]
+}
+
+// movedAt returns the moved line at the top of the view
+func (m diffModel) movedAt() (movedLine, bool) {
+	l, ok := m.moved[m.topLine()]
+	return l, ok
+}
+
+// scrollToPatchLine scrolls to a line of the file's patch, which follows the
+// commit message in the output of git show
+func (m *diffModel) scrollToPatchLine(line int) {
+	m.scrollToLine(patchOffset(m.diff) + line)
+}
+
+// scrollToLineNums scrolls to the diff line with the given line numbers. It
+// returns false if the line isn't in the diff.
+func (m *diffModel) scrollToLineNums(n lineNumbers) bool {
+	for i, nums := range m.lineNums {
+		if nums == n {
+			m.scrollToLine(i)
+			return true
+		}
+	}
+	return false
+}
+
+func movedStyle(l movedLine) lipgloss.Style {
+	if l.from {
+		return diffMovedFromStyles[l.block%2]
+	}
+	return diffMovedToStyles[l.block%2]
+}
+
+// movedSpans drops the syntax colors of a moved line so the block stands out,
+// keeping whitespace glyphs, errors and search matches, and adds the
+// annotation to the first line of the block
+func movedSpans(spans []span, l movedLine) []span {
+	var b spanBuilder
+	for _, s := range spans {
+		if s.kind != tokWhitespace && s.kind != tokWhitespaceError && s.kind != tokMatch {
+			s.kind = tokPlain
+		}
+		b.add(s.text, s.kind)
+	}
+	if l.first {
+		b.add("  "+l.describe(), tokNote)
+	}
+	return b
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// diffOption is an entry in the diff options menu
+type diffOption struct {
+	key   string
+	label string
+}
+
+var diffOptionList = []diffOption{
+	{"algorithm", "Diff algorithm"},
+	{"w", "Ignore all whitespace (-w)"},
+	{"b", "Ignore whitespace changes (-b)"},
+	{"blank", "Ignore blank lines"},
+	{"cr", "Ignore CR at end of line"},
+	{"eol", "Ignore whitespace at end of line"},
+	{"renames", "Rename threshold"},
+	{"copies", "Copy detection"},
+	{"tabwidth", "Tab width"},
+	{"whitespace", "Show whitespace"},
+}
+
+// optionsModel is a menu of diff options. It shows a copy of the options,
+// which are changed by the app.
+type optionsModel struct {
+	listModel
+	opts diffOptions
+}
+
+func newOptionsModel() optionsModel {
+	m := optionsModel{}
+	m.listModel.init(len(diffOptionList), false)
+	return m
+}
+
+func (m optionsModel) name() string {
+	return "options"
+}
+
+func (m optionsModel) selected() diffOption {
+	return diffOptionList[m.cursor]
+}
+
+func onOff(value bool) string {
+	if value {
+		return "on"
+	}
+	return "off"
+}
+
+func (m optionsModel) value(key string) string {
+	switch key {
+	case "algorithm":
+		return m.opts.algorithm
+	case "w":
+		return onOff(m.opts.ignoreWhitespace)
+	case "b":
+		return onOff(m.opts.ignoreSpaceChange)
+	case "blank":
+		return onOff(m.opts.ignoreBlankLines)
+	case "cr":
+		return onOff(m.opts.ignoreCrAtEol)
+	case "eol":
+		return onOff(m.opts.ignoreSpaceAtEol)
+	case "renames":
+		if renameThreshold == 0 {
+			return "off"
+		}
+		return fmt.Sprintf("%d%%", renameThreshold)
+	case "copies":
+		if copyDetection == "" {
+			return "off"
+		}
+		return copyDetection
+	case "tabwidth":
+		if m.opts.tabWidth == 0 {
+			return "auto"
+		}
+		return fmt.Sprint(m.opts.tabWidth)
+	case "whitespace":
+		return onOff(m.opts.showWhitespace)
+	}
+	return ""
+}
+
+// changeOption toggles or cycles an option
+func changeOption(opts *diffOptions, key string) {
+	switch key {
+	case "algorithm":
+		for i, a := range diffAlgorithms {
+			if a == opts.algorithm {
+				opts.algorithm = diffAlgorithms[(i+1)%len(diffAlgorithms)]
+				break
+			}
+		}
+	case "w":
+		opts.ignoreWhitespace = !opts.ignoreWhitespace
+	case "b":
+		opts.ignoreSpaceChange = !opts.ignoreSpaceChange
+	case "blank":
+		opts.ignoreBlankLines = !opts.ignoreBlankLines
+	case "cr":
+		opts.ignoreCrAtEol = !opts.ignoreCrAtEol
+	case "eol":
+		opts.ignoreSpaceAtEol = !opts.ignoreSpaceAtEol
+	case "renames":
+		// cycle through 10% to 100%, then off
+		if renameThreshold == 100 {
+			renameThreshold = 0
+		} else {
+			renameThreshold = renameThreshold/10*10 + 10
+		}
+	case "copies":
+		switch copyDetection {
+		case "":
+			copyDetection = "copies"
+		case "copies":
+			copyDetection = "harder"
+		default:
+			copyDetection = ""
+		}
+	case "tabwidth":
+		next := tabWidths[0]
+		for i, w := range tabWidths {
+			if w == opts.tabWidth {
+				next = tabWidths[(i+1)%len(tabWidths)]
+				break
+			}
+		}
+		opts.tabWidth = next
+	case "whitespace":
+		opts.showWhitespace = !opts.showWhitespace
+	}
+}
+
+// getOptionsStr returns short flags for the options that aren't the default
+func getOptionsStr(opts diffOptions) string {
+	flags := ""
+	if opts.algorithm != diffAlgorithms[0] {
+		flags += trunc(opts.algorithm, 4)
+	}
+	if opts.ignoreWhitespace {
+		flags += "W"
+	}
+	if opts.ignoreSpaceChange {
+		flags += "b"
+	}
+	if opts.ignoreBlankLines {
+		flags += "B"
+	}
+	if opts.ignoreCrAtEol {
+		flags += "R"
+	}
+	if opts.ignoreSpaceAtEol {
+		flags += "E"
+	}
+	if renameThreshold == 0 {
+		flags += "M-"
+	} else if renameThreshold != 50 {
+		flags += fmt.Sprintf("M%d", renameThreshold)
+	}
+	switch copyDetection {
+	case "copies":
+		flags += "C"
+	case "harder":
+		flags += "CC"
+	}
+	if opts.tabWidth != 0 {
+		flags += fmt.Sprintf("T%d", opts.tabWidth)
+	}
+	if opts.showWhitespace {
+		flags += "V"
+	}
+	return flags
+}
+
+func (m optionsModel) renderOption(index int) string {
+	o := diffOptionList[index]
+
+	if index == m.cursor {
+		optionLabelStyle.Background(cursorBg)
+		optionValueStyle.Background(cursorBg)
+	} else {
+		optionLabelStyle.UnsetBackground()
+		optionValueStyle.UnsetBackground()
+	}
+
+	optionValueStyle.Width(max(m.width-optionLabelStyle.GetWidth(), 0))
+
+	return lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		optionLabelStyle.Render(o.label),
+		optionValueStyle.Render(m.value(o.key)),
+	)
+}
+
+func (m optionsModel) render() string {
+	var lines []string
+	for i := m.start; i < m.end; i++ {
+		lines = append(lines, m.renderOption(i))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (m *optionsModel) findNext(re *regexp.Regexp) {
+	for i := m.cursor + 1; i < m.count; i++ {
+		if re.MatchString(diffOptionList[i].label) {
+			m.setCursor(i)
+			break
+		}
+	}
+}
+
+func (m *optionsModel) findPrev(re *regexp.Regexp) {
+	for i := m.cursor - 1; i >= 0; i-- {
+		if re.MatchString(diffOptionList[i].label) {
+			m.setCursor(i)
+			break
+		}
+	}
+}
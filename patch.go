@@ -0,0 +1,106 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// patchLine is a line of a generated patch view. The mark is shown in a
+// separate column in front of the text, and is used to show how a line
+// relates to another version of the patch.
+type patchLine struct {
+	mark byte
+	text string
+}
+
+// patchModel is a scrollable view of a patch that isn't read directly from
+// git, such as a comparison between two versions of a diff
+type patchModel struct {
+	listModel
+	title string
+	lines []patchLine
+}
+
+func newPatchModel() patchModel {
+	m := patchModel{}
+	m.listModel.init(0, true)
+	return m
+}
+
+func (m patchModel) name() string {
+	return "patch"
+}
+
+func (m *patchModel) setPatch(title string, lines []patchLine) {
+	m.title = title
+	m.lines = lines
+	m.listModel.init(len(lines), true)
+	m.start = 0
+	m.updateLayout()
+}
+
+// diffLineStyle returns the style of a line of git diff output
+func diffLineStyle(d string) lipgloss.Style {
+	if len(d) > 0 {
+		switch d[0] {
+		case '-':
+			return diffRemStyle
+		case '+':
+			return diffAddStyle
+		case '@':
+			return diffSepStyle
+		}
+	}
+
+	return diffNormalStyle
+}
+
+// styleDiffLine styles a line of git diff output
+func styleDiffLine(d string) string {
+	d = strings.ReplaceAll(d, "\t", "    ")
+	return diffLineStyle(d).Render(d)
+}
+
+func (m patchModel) renderPatchLine(index int) string {
+	l := m.lines[index]
+
+	mark := " "
+	switch l.mark {
+	case '+':
+		mark = diffAddStyle.Render("+")
+	case '-':
+		mark = diffRemStyle.Render("-")
+	case '~':
+		mark = diffModStyle.Render("~")
+	}
+
+	return mark + " " + styleDiffLine(l.text)
+}
+
+func (m patchModel) render() string {
+	var lines []string
+	for i := m.start; i < m.end; i++ {
+		lines = append(lines, m.renderPatchLine(i))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (m *patchModel) findNext(re *regexp.Regexp) {
+	for i := m.start + 1; i < m.count; i++ {
+		if re.MatchString(m.lines[i].text) {
+			m.scrollTo(i)
+			break
+		}
+	}
+}
+
+func (m *patchModel) findPrev(re *regexp.Regexp) {
+	for i := m.start - 1; i >= 0; i-- {
+		if re.MatchString(m.lines[i].text) {
+			m.scrollTo(i)
+			break
+		}
+	}
+}
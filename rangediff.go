@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// a commit pair line, like "1:  9aebaa1 ! 1:  89bd3b0 add d"
+var rangePairRe = regexp.MustCompile(
+	`^\s*(-|\d+):\s+(-+|[0-9a-f]+) ([=!<>]) \s*(-|\d+):\s+(-+|[0-9a-f]+) (.*)$`,
+)
+
+// rangePair is a pair of matched commits from git range-diff. One side is
+// empty for commits that were added or dropped.
+type rangePair struct {
+	oldHash string
+	newHash string
+	// status is '=' if the commits have the same patch, '!' if the patch
+	// changed, '<' if the commit was dropped, or '>' if it was added
+	status  byte
+	subject string
+	lines   []patchLine
+}
+
+type rangeDiffModel struct {
+	listModel
+	args  []string
+	pairs []rangePair
+}
+
+func newRangeDiffModel() rangeDiffModel {
+	m := rangeDiffModel{}
+	m.listModel.init(0, false)
+	return m
+}
+
+func (m rangeDiffModel) name() string {
+	return "rangediff"
+}
+
+func (m rangeDiffModel) selected() rangePair {
+	return m.pairs[m.cursor]
+}
+
+// parseRangeDiff parses the output of git range-diff into commit pairs. The
+// interdiff lines following each pair are split into the outer diff marker
+// and the inner patch line.
+func parseRangeDiff(output []string) (pairs []rangePair) {
+	for _, line := range output {
+		if match := rangePairRe.FindStringSubmatch(line); match != nil {
+			p := rangePair{status: match[3][0], subject: match[6]}
+			if match[1] != "-" {
+				p.oldHash = match[2]
+			}
+			if match[4] != "-" {
+				p.newHash = match[5]
+			}
+			pairs = append(pairs, p)
+			continue
+		}
+
+		if len(pairs) == 0 || !strings.HasPrefix(line, "    ") {
+			continue
+		}
+
+		p := &pairs[len(pairs)-1]
+		line = line[4:]
+		if strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "##") {
+			p.lines = append(p.lines, patchLine{' ', line})
+		} else if len(line) > 0 {
+			p.lines = append(p.lines, patchLine{line[0], line[1:]})
+		} else {
+			p.lines = append(p.lines, patchLine{' ', ""})
+		}
+	}
+	return
+}
+
+func (m *rangeDiffModel) setRange(args []string) error {
+	lines, err := gitRangeDiff(args)
+	if err != nil {
+		return err
+	}
+	m.args = args
+	m.pairs = parseRangeDiff(lines)
+	m.listModel.init(len(m.pairs), false)
+	return nil
+}
+
+// pairPatch returns the lines to show for a pair: the interdiff for matched
+// commits, or the whole patch, diffed with opts, for commits that were added
+// or dropped
+func (m rangeDiffModel) pairPatch(p rangePair, opts diffOptions) []patchLine {
+	var commit string
+	var mark byte
+	switch p.status {
+	case '<':
+		commit, mark = p.oldHash, '-'
+	case '>':
+		commit, mark = p.newHash, '+'
+	default:
+		return p.lines
+	}
+
+	var lines []patchLine
+	for _, line := range gitShowPatch(commit, opts) {
+		lines = append(lines, patchLine{mark, line})
+	}
+	return lines
+}
+
+func (m rangeDiffModel) getRangeStr() string {
+	return fmt.Sprintf("range-diff %s", strings.Join(m.args, " "))
+}
+
+func (m rangeDiffModel) renderPair(index int) string {
+	p := m.pairs[index]
+
+	if index == m.cursor {
+		markerStyle.Background(cursorBg)
+		hashStyle.Background(cursorBg)
+		subjectStyle.Background(cursorBg)
+	} else {
+		markerStyle.UnsetBackground()
+		hashStyle.UnsetBackground()
+		subjectStyle.UnsetBackground()
+	}
+
+	status := string(p.status)
+	switch p.status {
+	case '!':
+		status = diffModStyle.Render(status)
+	case '<':
+		status = diffRemStyle.Render(status)
+	case '>':
+		status = diffAddStyle.Render(status)
+	}
+
+	oldHash := "-"
+	if p.oldHash != "" {
+		oldHash = trunc(p.oldHash, 8)
+	}
+	newHash := "-"
+	if p.newHash != "" {
+		newHash = trunc(p.newHash, 8)
+	}
+
+	subjectStyle.Width(m.width -
+		markerStyle.GetWidth() -
+		2*hashStyle.GetWidth())
+
+	return lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		markerStyle.Render(status),
+		hashStyle.Render(oldHash),
+		hashStyle.Render(newHash),
+		subjectStyle.Render(p.subject),
+	)
+}
+
+func (m rangeDiffModel) render() string {
+	var lines []string
+	if m.end-m.start == 0 {
+		for i := 0; i < m.height/4; i++ {
+			lines = append(lines, "")
+		}
+		centerStyle := lipgloss.NewStyle().
+			Align(lipgloss.Center).
+			Width(m.width)
+		lines = append(lines, centerStyle.Render("No commits"))
+		return lipgloss.JoinVertical(lipgloss.Center, lines...)
+	} else {
+		for i := m.start; i < m.end; i++ {
+			lines = append(lines, m.renderPair(i))
+		}
+		return lipgloss.JoinVertical(lipgloss.Left, lines...)
+	}
+}
+
+func (m *rangeDiffModel) findNext(re *regexp.Regexp) {
+	for i := m.cursor + 1; i < m.count; i++ {
+		if re.MatchString(m.pairs[i].subject) {
+			m.setCursor(i)
+			break
+		}
+	}
+}
+
+func (m *rangeDiffModel) findPrev(re *regexp.Regexp) {
+	for i := m.cursor - 1; i >= 0; i-- {
+		if re.MatchString(m.pairs[i].subject) {
+			m.setCursor(i)
+			break
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// searchResult is a line that matches a range-wide search. Line is the index
+// of the line in the file's patch.
+type searchResult struct {
+	stat    stat
+	line    int
+	lineNum int
+	text    string
+}
+
+// searchRow is a row of the search results view: a file header, or a match
+// if result isn't -1
+type searchRow struct {
+	path   string
+	count  int
+	result int
+}
+
+// searchModel lists the lines matching a search in every file of a range,
+// grouped by file
+type searchModel struct {
+	listModel
+	pattern string
+	commits commitRange
+	results []searchResult
+	rows    []searchRow
+	// current is the result that was last shown in the diff view, and
+	// currentTop the row at the top of the diff view when it was shown
+	current    int
+	currentTop int
+}
+
+func newSearchModel() searchModel {
+	m := searchModel{}
+	m.listModel.init(0, false)
+	return m
+}
+
+func (m searchModel) name() string {
+	return "search"
+}
+
+// searchRange searches the content lines of the patches of stats, in the
+// order of stats
+func searchRange(c commitRange, stats []stat, opts diffOptions, re *regexp.Regexp) []searchResult {
+	_, patches := splitPatches(gitDiff(c.start, c.end, "", "", opts))
+
+	var results []searchResult
+	for _, s := range stats {
+		patch := patches[s.Path]
+		nums := numberDiffLines(patch)
+		for i, line := range patch {
+			if nums[i] == (lineNumbers{}) || !re.MatchString(line[1:]) {
+				continue
+			}
+			r := searchResult{stat: s, line: i, lineNum: nums[i].new, text: line}
+			if strings.HasPrefix(line, "-") {
+				r.lineNum = nums[i].old
+			}
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+func (m *searchModel) setResults(pattern string, c commitRange, results []searchResult) {
+	m.pattern = pattern
+	m.commits = c
+	m.results = results
+	m.current = -1
+
+	m.rows = nil
+	header := -1
+	for i, r := range results {
+		if i == 0 || r.stat.Path != results[i-1].stat.Path {
+			header = len(m.rows)
+			m.rows = append(m.rows, searchRow{path: r.stat.Path, result: -1})
+		}
+		m.rows[header].count++
+		m.rows = append(m.rows, searchRow{path: r.stat.Path, result: i})
+	}
+
+	m.listModel.init(len(m.rows), false)
+	m.start = 0
+	m.updateLayout()
+}
+
+// selected returns the result at the cursor, or the first result of the file
+// if the cursor is on a file header
+func (m searchModel) selected() int {
+	if m.cursor < 0 {
+		return -1
+	}
+	if r := m.rows[m.cursor]; r.result >= 0 {
+		return r.result
+	}
+	return m.rows[m.cursor+1].result
+}
+
+// step returns the result after, or before, line of the patch of path. It
+// continues into the next or previous file, and returns -1 past the last or
+// first result.
+func (m searchModel) step(path string, line int, forward bool) int {
+	first, last := -1, -1
+	for i, r := range m.results {
+		if r.stat.Path == path {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+
+	next := -1
+	switch {
+	case first < 0 && forward:
+		next = m.current + 1
+	case first < 0:
+		next = m.current - 1
+	case forward:
+		next = last + 1
+		for i := first; i <= last; i++ {
+			if m.results[i].line > line {
+				next = i
+				break
+			}
+		}
+	default:
+		next = first - 1
+		for i := last; i >= first; i-- {
+			if m.results[i].line < line {
+				next = i
+				break
+			}
+		}
+	}
+
+	if next < 0 || next >= len(m.results) {
+		return -1
+	}
+	return next
+}
+
+// setCurrent moves the cursor to a result
+func (m *searchModel) setCurrent(result int) {
+	m.current = result
+	for i, r := range m.rows {
+		if r.result == result {
+			m.setCursor(i)
+			break
+		}
+	}
+}
+
+// getSearchStr describes the search and its results
+func (m searchModel) getSearchStr() string {
+	files := len(m.rows) - len(m.results)
+	return fmt.Sprintf("search %q: %d matches in %d files", m.pattern, len(m.results), files)
+}
+
+func (m searchModel) renderRow(index int) string {
+	r := m.rows[index]
+
+	style := statStyle
+	if index == m.cursor {
+		style.Background(cursorBg)
+		searchFileStyle.Background(cursorBg)
+	} else {
+		style.UnsetBackground()
+		searchFileStyle.UnsetBackground()
+	}
+	style.Width(m.width)
+	searchFileStyle.Width(m.width)
+
+	if r.result < 0 {
+		return searchFileStyle.Render(fmt.Sprintf("%s (%d)", r.path, r.count))
+	}
+
+	res := m.results[r.result]
+	text := "  " + strconv.Itoa(res.lineNum) + ": " + strings.ReplaceAll(res.text, "\t", "    ")
+	if runes := []rune(text); len(runes) > m.width {
+		text = string(runes[:m.width])
+	}
+	return renderMatches(text, m.query, style)
+}
+
+func (m searchModel) render() string {
+	var lines []string
+	if m.end-m.start == 0 {
+		for i := 0; i < m.height/4; i++ {
+			lines = append(lines, "")
+		}
+		centerStyle := lipgloss.NewStyle().
+			Align(lipgloss.Center).
+			Width(m.width)
+		lines = append(lines, centerStyle.Render("No matches"))
+		return lipgloss.JoinVertical(lipgloss.Center, lines...)
+	}
+
+	for i := m.start; i < m.end; i++ {
+		lines = append(lines, m.renderRow(i))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (m *searchModel) findNext(re *regexp.Regexp) {
+	for i := m.cursor + 1; i < m.count; i++ {
+		r := m.rows[i]
+		if r.result >= 0 && re.MatchString(m.results[r.result].text) {
+			m.setCursor(i)
+			break
+		}
+	}
+}
+
+func (m *searchModel) findPrev(re *regexp.Regexp) {
+	for i := m.cursor - 1; i >= 0; i-- {
+		r := m.rows[i]
+		if r.result >= 0 && re.MatchString(m.results[r.result].text) {
+			m.setCursor(i)
+			break
+		}
+	}
+}
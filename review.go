@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// reviewStore records which files have been reviewed. Marks are keyed by
+// commit range and path, and store the hash of the reviewed version of the
+// file so that a file goes back to unreviewed when its content changes.
+type reviewStore struct {
+	Marks map[string]map[string]string
+}
+
+func getReviewFile() string {
+	return filepath.Join(getGitDir(), "de", "reviewed.json")
+}
+
+func loadReviews() reviewStore {
+	r := reviewStore{}
+	if data, err := os.ReadFile(getReviewFile()); err == nil {
+		json.Unmarshal(data, &r)
+	}
+	if r.Marks == nil {
+		r.Marks = map[string]map[string]string{}
+	}
+	return r
+}
+
+func (r reviewStore) save() error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	file := getReviewFile()
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, data, 0644)
+}
+
+func rangeKey(c commitRange) string {
+	return c.start + ".." + c.end
+}
+
+// reviewedStats returns the indexes of the stats that are marked as reviewed
+// in range c and haven't changed since
+func (r reviewStore) reviewedStats(c commitRange, stats []stat) map[int]bool {
+	reviewed := map[int]bool{}
+	marks := r.Marks[rangeKey(c)]
+	if len(marks) == 0 {
+		return reviewed
+	}
+
+	var marked []int
+	for i, s := range stats {
+		if _, ok := marks[s.Path]; ok {
+			marked = append(marked, i)
+		}
+	}
+
+	hashes := blobHashes(stats, marked)
+	for _, i := range marked {
+		if marks[stats[i].Path] == hashes[i] {
+			reviewed[i] = true
+		}
+	}
+
+	return reviewed
+}
+
+// setReviewed marks or unmarks a stat as reviewed in range c
+func (r reviewStore) setReviewed(c commitRange, s stat, reviewed bool) error {
+	key := rangeKey(c)
+	if reviewed {
+		if r.Marks[key] == nil {
+			r.Marks[key] = map[string]string{}
+		}
+		r.Marks[key][s.Path] = blobHashes([]stat{s}, []int{0})[0]
+	} else {
+		delete(r.Marks[key], s.Path)
+		if len(r.Marks[key]) == 0 {
+			delete(r.Marks, key)
+		}
+	}
+	return r.save()
+}
+
+// blobHashes returns a hash identifying the current content of each of the
+// given stats. Worktree files are hashed with git hash-object.
+func blobHashes(stats []stat, indexes []int) map[int]string {
+	hashes := map[int]string{}
+	var worktree []int
+	for _, i := range indexes {
+		s := stats[i]
+		if s.Status == "D" {
+			hashes[i] = "deleted:" + s.OldHash
+		} else if s.NewHash != nullHash {
+			hashes[i] = s.NewHash
+		} else {
+			worktree = append(worktree, i)
+		}
+	}
+
+	if len(worktree) > 0 {
+		var paths []string
+		for _, i := range worktree {
+			paths = append(paths, stats[i].Path)
+		}
+		for j, hash := range gitHashObjects(paths) {
+			hashes[worktree[j]] = hash
+		}
+	}
+
+	return hashes
+}
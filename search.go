@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// compileQuery compiles a search query. Queries starting with "re:" are
+// regular expressions, and other queries match literally. Queries are case
+// insensitive unless they contain an uppercase letter.
+func compileQuery(query string) (*regexp.Regexp, error) {
+	expr := regexp.QuoteMeta(query)
+	if strings.HasPrefix(query, "re:") {
+		query = strings.TrimPrefix(query, "re:")
+		expr = query
+	}
+
+	hasUpper := strings.IndexFunc(query, unicode.IsUpper) >= 0
+	if !hasUpper {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// matchSpans splits spans at the matches of re, which are marked as tokMatch
+func matchSpans(spans []span, re *regexp.Regexp) []span {
+	if re == nil {
+		return spans
+	}
+
+	text := ""
+	for _, s := range spans {
+		text += s.text
+	}
+	locs := re.FindAllStringIndex(text, -1)
+	if len(locs) == 0 {
+		return spans
+	}
+
+	matched := make([]bool, len(text))
+	for _, loc := range locs {
+		for i := loc[0]; i < loc[1]; i++ {
+			matched[i] = true
+		}
+	}
+
+	var b spanBuilder
+	pos := 0
+	for _, s := range spans {
+		start := 0
+		for i := 1; i <= len(s.text); i++ {
+			if i == len(s.text) || matched[pos+i] != matched[pos+start] {
+				kind := s.kind
+				if matched[pos+start] {
+					kind = tokMatch
+				}
+				b.add(s.text[start:i], kind)
+				start = i
+			}
+		}
+		pos += len(s.text)
+	}
+	return b
+}
+
+// renderMatches renders text in a style with the matches of re highlighted,
+// padded to the style's width
+func renderMatches(text string, re *regexp.Regexp, style lipgloss.Style) string {
+	if re == nil || !re.MatchString(text) {
+		return style.Render(text)
+	}
+
+	width := style.GetWidth()
+	inline := style.Copy()
+	inline.UnsetWidth()
+	match := inline.Copy()
+	match.Background(searchMatchBg)
+	match.Foreground(searchMatchFg)
+
+	var b strings.Builder
+	for _, s := range matchSpans([]span{{text, tokPlain}}, re) {
+		if s.kind == tokMatch {
+			b.WriteString(match.Render(s.text))
+		} else {
+			b.WriteString(inline.Render(s.text))
+		}
+	}
+
+	out := b.String()
+	if pad := width - lipgloss.Width(out); pad > 0 {
+		out += inline.Render(strings.Repeat(" ", pad))
+	}
+	return out
+}
+
+// getMatchStr describes the position of pos among the sorted indexes of the
+// items that match a search. Before the first match, the position is "-".
+func getMatchStr(matches []int, pos int) string {
+	if len(matches) == 0 {
+		return "no matches"
+	}
+	i := sort.SearchInts(matches, pos+1)
+	if i == 0 {
+		return fmt.Sprintf("match -/%d", len(matches))
+	}
+	return fmt.Sprintf("match %d/%d", i, len(matches))
+}
+
+// nextMatch returns the first of the sorted matches after pos, or -1
+func nextMatch(matches []int, pos int) int {
+	if i := sort.SearchInts(matches, pos+1); i < len(matches) {
+		return matches[i]
+	}
+	return -1
+}
+
+// prevMatch returns the last of the sorted matches before pos, or -1
+func prevMatch(matches []int, pos int) int {
+	if i := sort.SearchInts(matches, pos); i > 0 {
+		return matches[i-1]
+	}
+	return -1
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var snapshotNameRe = regexp.MustCompile(`^[\w.-]+$`)
+
+// snapshot is a saved copy of a worktree diff
+type snapshot struct {
+	Name    string
+	Time    time.Time
+	Start   string
+	Stats   []stat
+	Patches map[string][]string
+}
+
+func getSnapshotDir() string {
+	return filepath.Join(getGitDir(), "de", "snapshots")
+}
+
+// takeSnapshot saves the diff between start and the worktree under name
+func takeSnapshot(name, start string, opts diffOptions) error {
+	if !snapshotNameRe.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+
+	s := snapshot{
+		Name:    name,
+		Time:    time.Now(),
+		Start:   start,
+		Stats:   gitDiffStat(start, ""),
+		Patches: map[string][]string{},
+	}
+	for _, st := range s.Stats {
+		s.Patches[st.Path] = gitDiff(start, "", st.Path, st.OldPath, opts)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	dir := getSnapshotDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, name+".json"), data, 0644)
+}
+
+// loadSnapshot loads a named snapshot, or the most recent one if name is empty
+func loadSnapshot(name string) (s snapshot, err error) {
+	if name == "" {
+		names := listSnapshots()
+		if len(names) == 0 {
+			return s, fmt.Errorf("no snapshots")
+		}
+		name = names[len(names)-1]
+	}
+	if !snapshotNameRe.MatchString(name) {
+		return s, fmt.Errorf("invalid snapshot name %q", name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(getSnapshotDir(), name+".json"))
+	if err != nil {
+		return s, err
+	}
+
+	err = json.Unmarshal(data, &s)
+	return
+}
+
+// listSnapshots returns the names of all snapshots, oldest first
+func listSnapshots() []string {
+	entries, err := os.ReadDir(getSnapshotDir())
+	if err != nil {
+		return nil
+	}
+
+	type entry struct {
+		name string
+		time time.Time
+	}
+	var found []entry
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".json")
+		if info, err := e.Info(); err == nil && name != e.Name() {
+			found = append(found, entry{name, info.ModTime()})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].time.Before(found[j].time)
+	})
+
+	var names []string
+	for _, f := range found {
+		names = append(names, f.name)
+	}
+	return names
+}
+
+type hunk struct {
+	header string
+	body   []string
+}
+
+// splitHunks splits a patch into its file header and hunks
+func splitHunks(patch []string) (header []string, hunks []hunk) {
+	for _, line := range patch {
+		if strings.HasPrefix(line, "@@") {
+			hunks = append(hunks, hunk{header: line})
+		} else if len(hunks) == 0 {
+			header = append(header, line)
+		} else {
+			h := &hunks[len(hunks)-1]
+			h.body = append(h.body, line)
+		}
+	}
+	return
+}
+
+func (h hunk) key() string {
+	return strings.Join(h.body, "\n")
+}
+
+// compareHunks compares two versions of a patch hunk by hunk. Hunks that are
+// the same in both versions are collapsed to their headers. Hunks that are
+// only in the new version are marked with '+', and hunks that are only in the
+// old version are marked with '-'. Line offsets are ignored, so hunks that
+// only moved are considered the same.
+func compareHunks(oldPatch, newPatch []string) (lines []patchLine, changed bool) {
+	_, oldHunks := splitHunks(oldPatch)
+	_, newHunks := splitHunks(newPatch)
+
+	oldKeys := map[string]int{}
+	for _, h := range oldHunks {
+		oldKeys[h.key()]++
+	}
+	newKeys := map[string]int{}
+	for _, h := range newHunks {
+		newKeys[h.key()]++
+	}
+
+	for _, h := range newHunks {
+		if oldKeys[h.key()] > 0 {
+			oldKeys[h.key()]--
+			lines = append(lines, patchLine{' ', h.header + " (unchanged)"})
+			continue
+		}
+		changed = true
+		lines = append(lines, patchLine{'+', h.header})
+		for _, l := range h.body {
+			lines = append(lines, patchLine{'+', l})
+		}
+	}
+
+	for _, h := range oldHunks {
+		if newKeys[h.key()] > 0 {
+			newKeys[h.key()]--
+			continue
+		}
+		changed = true
+		lines = append(lines, patchLine{'-', h.header})
+		for _, l := range h.body {
+			lines = append(lines, patchLine{'-', l})
+		}
+	}
+
+	return
+}
+
+// interdiffFile is a file that differs between a snapshot and the current diff
+type interdiffFile struct {
+	// state is 'A' if the file is only in the current diff, 'D' if it's only
+	// in the snapshot, or 'M' if its patch changed
+	state byte
+	path  string
+	lines []patchLine
+}
+
+// interdiffModel lists the files whose patches differ between a snapshot and
+// the current worktree diff
+type interdiffModel struct {
+	listModel
+	snapshot snapshot
+	files    []interdiffFile
+}
+
+func newInterdiffModel() interdiffModel {
+	m := interdiffModel{}
+	m.listModel.init(0, false)
+	return m
+}
+
+func (m interdiffModel) name() string {
+	return "interdiff"
+}
+
+func (m interdiffModel) selected() interdiffFile {
+	return m.files[m.cursor]
+}
+
+// setSnapshot compares a snapshot to the current diff of the worktree against
+// the snapshot's start commit
+func (m *interdiffModel) setSnapshot(s snapshot, opts diffOptions) {
+	m.snapshot = s
+	m.files = nil
+
+	current := map[string]bool{}
+	for _, st := range gitDiffStat(s.Start, "") {
+		current[st.Path] = true
+		patch := gitDiff(s.Start, "", st.Path, st.OldPath, opts)
+		old, ok := s.Patches[st.Path]
+		if !ok {
+			lines, _ := compareHunks(nil, patch)
+			m.files = append(m.files, interdiffFile{'A', st.Path, lines})
+		} else if lines, changed := compareHunks(old, patch); changed {
+			m.files = append(m.files, interdiffFile{'M', st.Path, lines})
+		}
+	}
+
+	for _, st := range s.Stats {
+		if !current[st.Path] {
+			lines, _ := compareHunks(s.Patches[st.Path], nil)
+			m.files = append(m.files, interdiffFile{'D', st.Path, lines})
+		}
+	}
+
+	m.listModel.init(len(m.files), false)
+}
+
+func (m interdiffModel) renderFile(index int) string {
+	f := m.files[index]
+
+	if index == m.cursor {
+		statModStyle.Background(cursorBg)
+		statStyle.Background(cursorBg)
+	} else {
+		statModStyle.UnsetBackground()
+		statStyle.UnsetBackground()
+	}
+
+	statStyle.Width(m.width)
+
+	return lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		renderStatus(string(f.state)),
+		statStyle.Render(f.path),
+	)
+}
+
+func (m interdiffModel) render() string {
+	var lines []string
+	if m.end-m.start == 0 {
+		for i := 0; i < m.height/4; i++ {
+			lines = append(lines, "")
+		}
+		centerStyle := lipgloss.NewStyle().
+			Align(lipgloss.Center).
+			Width(m.width)
+		lines = append(lines, centerStyle.Render("No changes since snapshot"))
+		return lipgloss.JoinVertical(lipgloss.Center, lines...)
+	} else {
+		for i := m.start; i < m.end; i++ {
+			lines = append(lines, m.renderFile(i))
+		}
+		return lipgloss.JoinVertical(lipgloss.Left, lines...)
+	}
+}
+
+func (m *interdiffModel) findNext(re *regexp.Regexp) {
+	for i := m.cursor + 1; i < m.count; i++ {
+		if re.MatchString(m.files[i].path) {
+			m.setCursor(i)
+			break
+		}
+	}
+}
+
+func (m *interdiffModel) findPrev(re *regexp.Regexp) {
+	for i := m.cursor - 1; i >= 0; i-- {
+		if re.MatchString(m.files[i].path) {
+			m.setCursor(i)
+			break
+		}
+	}
+}
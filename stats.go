@@ -2,7 +2,8 @@ package main
 
 import (
 	"fmt"
-	"math"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
@@ -11,13 +12,34 @@ import (
 type statsModel struct {
 	listModel
 	stats     []stat
+	rows      []statRow
 	addsWidth int
 	delsWidth int
 	commits   commitRange
+	treeMode  bool
+	collapsed map[string]bool
+	sortBy    string
+	filter    statFilter
+	showBars  bool
+	totals    statTotals
+
+	reviews      reviewStore
+	reviewed     map[int]bool
+	hideReviewed bool
+}
+
+// statTotals summarizes the files shown in the stats view
+type statTotals struct {
+	files    int
+	adds     int
+	dels     int
+	binaries int
+	// maxChurn is the largest number of changed lines in a file
+	maxChurn int
 }
 
 func newStatsModel() statsModel {
-	m := statsModel{}
+	m := statsModel{sortBy: statSortModes[0], reviews: loadReviews()}
 	m.listModel.init(0, true)
 	return m
 }
@@ -26,12 +48,38 @@ func (m statsModel) name() string {
 	return "stats"
 }
 
+// the stats view shows a summary line below the list
+func (m *statsModel) setHeight(height int) {
+	m.listModel.setHeight(height - 1)
+}
+
+func (m *statsModel) setSize(width, height int) {
+	m.setWidth(width)
+	m.setHeight(height)
+}
+
 func (m statsModel) stat(index int) stat {
 	return m.stats[index]
 }
 
+// selected returns the stat at the cursor, which must be on a file row
 func (m statsModel) selected() stat {
-	return m.stats[m.cursor]
+	return m.stats[m.rows[m.cursor].index]
+}
+
+// selectedPath returns the path of the file or directory at the cursor
+func (m statsModel) selectedPath() string {
+	if m.cursor < 0 {
+		return ""
+	}
+	if r := m.rows[m.cursor]; r.isDir() {
+		return r.dir
+	}
+	return m.selected().Path
+}
+
+func (m statsModel) isFileSelected() bool {
+	return m.cursor >= 0 && !m.rows[m.cursor].isDir()
 }
 
 func (m statsModel) getCommitsStr() string {
@@ -46,29 +94,291 @@ func (m statsModel) getCommitsStr() string {
 
 func (m *statsModel) setDiff(c commitRange) {
 	m.commits = c
-	if c.start == c.end {
-		m.stats = gitShow(c.start)
+	m.collapsed = map[string]bool{}
+	m.loadStats()
+	m.buildRows()
+	m.listModel.init(len(m.rows), false)
+}
+
+// buildRows lays out the stats as rows for the current mode
+func (m *statsModel) buildRows() {
+	order := sortStats(m.stats, m.filter, m.sortBy)
+	if m.hideReviewed {
+		var unreviewed []int
+		for _, i := range order {
+			if !m.reviewed[i] {
+				unreviewed = append(unreviewed, i)
+			}
+		}
+		order = unreviewed
+	}
+	if m.treeMode {
+		m.rows = treeStatRows(m.stats, order, m.collapsed)
 	} else {
-		m.stats = gitDiffStat(c.start, c.end)
+		m.rows = flatStatRows(m.stats, order)
 	}
-	m.listModel.init(len(m.stats), false)
+
 	m.addsWidth = 0
 	m.delsWidth = 0
-	for _, stat := range m.stats {
-		addDigits := int(math.Floor(math.Log10(float64(stat.Adds)))) + 1
-		delDigits := int(math.Floor(math.Log10(float64(stat.Dels)))) + 1
-		m.addsWidth = max(m.addsWidth, addDigits)
-		m.delsWidth = max(m.delsWidth, delDigits)
+	for _, row := range m.rows {
+		m.addsWidth = max(m.addsWidth, len(strconv.Itoa(row.adds)))
+		m.delsWidth = max(m.delsWidth, len(strconv.Itoa(row.dels)))
+	}
+
+	m.totals = statTotals{}
+	for _, i := range order {
+		s := m.stats[i]
+		m.totals.files++
+		m.totals.adds += s.Adds
+		m.totals.dels += s.Dels
+		m.totals.maxChurn = max(m.totals.maxChurn, s.Adds+s.Dels)
+		if s.Binary {
+			m.totals.binaries++
+		}
 	}
+
+	m.setQuery(m.query)
+	m.listModel.setCount(len(m.rows))
+}
+
+// setQuery sets the search whose matches are highlighted and counted
+func (m *statsModel) setQuery(re *regexp.Regexp) {
+	m.query = re
+	m.found = nil
+	if re != nil {
+		m.found = m.matches(re)
+	}
+}
+
+// relayout rebuilds the rows, keeping the cursor on the same path if it's
+// still visible
+func (m *statsModel) relayout() {
+	path := m.selectedPath()
+	m.buildRows()
+	if index := m.rowOf(path); index >= 0 {
+		m.setCursor(index)
+	} else if m.cursor < 0 && m.count > 0 {
+		m.setCursor(0)
+	}
+}
+
+func (m *statsModel) loadStats() {
+	if m.commits.start == m.commits.end {
+		m.stats = gitShow(m.commits.start)
+	} else {
+		m.stats = gitDiffStat(m.commits.start, m.commits.end)
+	}
+	markGenerated(m.stats)
+	m.reviewed = m.reviews.reviewedStats(m.commits, m.stats)
+}
+
+// sortedStats returns every stat in the current sort order, including files
+// that are filtered out or hidden
+func (m statsModel) sortedStats() []stat {
+	var stats []stat
+	for _, i := range sortStats(m.stats, statFilter{}, m.sortBy) {
+		stats = append(stats, m.stats[i])
+	}
+	return stats
+}
+
+// toggleReviewed marks or unmarks the file at the cursor as reviewed
+func (m *statsModel) toggleReviewed() error {
+	if !m.isFileSelected() {
+		return nil
+	}
+
+	index := m.rows[m.cursor].index
+	reviewed := !m.reviewed[index]
+	if err := m.reviews.setReviewed(m.commits, m.stats[index], reviewed); err != nil {
+		return err
+	}
+	m.reviewed[index] = reviewed
+
+	if m.hideReviewed && reviewed {
+		cursor := m.cursor
+		m.buildRows()
+		m.setCursor(min(cursor, m.count-1))
+	}
+	return nil
+}
+
+func (m *statsModel) toggleHideReviewed() {
+	m.hideReviewed = !m.hideReviewed
+	m.relayout()
+}
+
+// getReviewStr describes review progress
+func (m statsModel) getReviewStr() string {
+	count := 0
+	for _, reviewed := range m.reviewed {
+		if reviewed {
+			count++
+		}
+	}
+	if count == 0 {
+		return ""
+	}
+
+	str := fmt.Sprintf(" reviewed %d/%d", count, len(m.stats))
+	if m.hideReviewed {
+		str += " (hidden)"
+	}
+	return str
 }
 
 func (m *statsModel) refresh() {
-	m.stats = gitDiffStat(m.commits.start, m.commits.end)
-	m.listModel.setCount(len(m.stats))
+	if m.commits.start == "" {
+		return
+	}
+	m.loadStats()
+	m.relayout()
+}
+
+// expandTo expands the collapsed directories containing path in tree mode, so
+// its row is shown
+func (m *statsModel) expandTo(path string) {
+	if !m.treeMode {
+		return
+	}
+	expanded := false
+	for dir := range m.collapsed {
+		if strings.HasPrefix(path, dir+"/") {
+			delete(m.collapsed, dir)
+			expanded = true
+		}
+	}
+	if expanded {
+		m.buildRows()
+	}
+}
+
+// hasPath returns true if the file at path has changes in the range, even if
+// its row is hidden
+func (m statsModel) hasPath(path string) bool {
+	for _, s := range m.stats {
+		if s.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// rowOf returns the row index of the file or directory at path, or -1 if it
+// has no row or is hidden
+func (m statsModel) rowOf(path string) int {
+	for i, r := range m.rows {
+		if r.dir == path || (!r.isDir() && m.stats[r.index].Path == path) {
+			return i
+		}
+	}
+	return -1
+}
+
+// nextFile moves the cursor to the next file row
+func (m *statsModel) nextFile() {
+	for i := m.cursor + 1; i < m.count; i++ {
+		if !m.rows[i].isDir() {
+			m.setCursor(i)
+			return
+		}
+	}
+}
+
+// prevFile moves the cursor to the previous file row
+func (m *statsModel) prevFile() {
+	for i := m.cursor - 1; i >= 0; i-- {
+		if !m.rows[i].isDir() {
+			m.setCursor(i)
+			return
+		}
+	}
+}
+
+// nextSort switches to the next sort order
+func (m *statsModel) nextSort() {
+	for i, mode := range statSortModes {
+		if mode == m.sortBy {
+			m.sortBy = statSortModes[(i+1)%len(statSortModes)]
+			break
+		}
+	}
+	m.relayout()
+}
+
+func (m *statsModel) setFilter(text string) error {
+	f, err := parseStatFilter(text)
+	if err != nil {
+		return err
+	}
+	m.filter = f
+	m.relayout()
+	return nil
+}
+
+// getViewStr describes the active sort and filter
+func (m statsModel) getViewStr() string {
+	var parts []string
+	if m.sortBy != statSortModes[0] {
+		parts = append(parts, "sort:"+m.sortBy)
+	}
+	if !m.filter.isEmpty() {
+		parts = append(parts, "filter:"+m.filter.text)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(parts, " ") + "]"
+}
+
+// toggleTree switches between flat and tree modes
+func (m *statsModel) toggleTree() {
+	m.treeMode = !m.treeMode
+	m.relayout()
+}
+
+// expand expands the directory at the cursor
+func (m *statsModel) expand() {
+	if m.cursor >= 0 {
+		if r := m.rows[m.cursor]; r.isDir() && m.collapsed[r.dir] {
+			delete(m.collapsed, r.dir)
+			m.buildRows()
+		}
+	}
+}
+
+// collapse collapses the directory at the cursor, or moves to the parent
+// directory of a file or collapsed directory
+func (m *statsModel) collapse() {
+	if m.cursor < 0 || !m.treeMode {
+		return
+	}
+
+	r := m.rows[m.cursor]
+	if r.isDir() && !m.collapsed[r.dir] {
+		m.collapsed[r.dir] = true
+		m.buildRows()
+		return
+	}
+
+	for i := m.cursor - 1; i >= 0; i-- {
+		if m.rows[i].isDir() && m.rows[i].depth < r.depth {
+			m.setCursor(i)
+			return
+		}
+	}
+}
+
+// toggleCollapsed expands or collapses the directory at the cursor
+func (m *statsModel) toggleCollapsed() {
+	if r := m.rows[m.cursor]; r.isDir() {
+		m.collapsed[r.dir] = !m.collapsed[r.dir]
+		m.buildRows()
+	}
 }
 
 func (m statsModel) renderStat(index int) string {
-	s := m.stats[index]
+	r := m.rows[index]
 	parts := []string{}
 
 	if index == m.cursor {
@@ -76,25 +386,74 @@ func (m statsModel) renderStat(index int) string {
 		statAddStyle.Background(cursorBg)
 		statDelStyle.Background(cursorBg)
 		statModStyle.Background(cursorBg)
+		statReviewedStyle.Background(cursorBg)
 	} else {
 		statStyle.UnsetBackground()
 		statAddStyle.UnsetBackground()
 		statDelStyle.UnsetBackground()
 		statModStyle.UnsetBackground()
+		statReviewedStyle.UnsetBackground()
+	}
+
+	marker := ""
+	status := ""
+	adds := fmt.Sprintf("%d", r.adds)
+	dels := fmt.Sprintf("%d", r.dels)
+	if !r.isDir() {
+		if m.reviewed[r.index] {
+			marker = "✓"
+		}
+		status = m.stats[r.index].Status
+		if m.stats[r.index].Binary {
+			adds = "-"
+			dels = "-"
+		}
 	}
+	parts = append(parts, statReviewedStyle.Render(marker))
+	parts = append(parts, renderStatus(status))
 
 	statAddStyle.Width(m.addsWidth + 1).PaddingRight(1)
-	parts = append(parts, statAddStyle.Render(fmt.Sprintf("%d", s.Adds)))
+	parts = append(parts, statAddStyle.Render(adds))
 	statDelStyle.Width(m.delsWidth + 1).PaddingRight(1)
-	parts = append(parts, statDelStyle.Render(fmt.Sprintf("%d", s.Dels)))
+	parts = append(parts, statDelStyle.Render(dels))
+
+	if m.showBars {
+		parts = append(parts, m.renderBar(r.adds, r.dels))
+	}
 
-	statStyle.Width(m.width)
+	statStyle.Width(max(m.width-lipgloss.Width(strings.Join(parts, "")), 0))
 
-	path := s.Path
-	if s.OldPath != "" {
-		path = path + " ← " + s.OldPath
+	var path string
+	if r.isDir() {
+		arrow := "▾"
+		if m.collapsed[r.dir] {
+			arrow = "▸"
+		}
+		path = fmt.Sprintf("%s %s/", arrow, r.label)
+	} else {
+		s := m.stats[r.index]
+		path = s.Path
+		if m.treeMode {
+			path = "  " + r.label
+		}
+		if s.OldPath != "" {
+			path = path + " ← " + s.OldPath
+		}
+		if s.Status == "R" || s.Status == "C" {
+			path += fmt.Sprintf(" (%d%%)", s.Score)
+		}
+		if change := describeModeChange(s); change != "" {
+			path += " [" + change + "]"
+		}
+		if s.Binary {
+			path += " [binary]"
+		}
+		if s.Generated {
+			path += " [generated]"
+		}
 	}
-	parts = append(parts, statStyle.Render(path))
+	path = strings.Repeat("  ", r.depth) + path
+	parts = append(parts, renderMatches(path, m.query, statStyle))
 
 	return lipgloss.JoinHorizontal(
 		lipgloss.Top,
@@ -102,6 +461,54 @@ func (m statsModel) renderStat(index int) string {
 	)
 }
 
+// barWidth returns the width of the churn bars
+func (m statsModel) barWidth() int {
+	return min(40, m.width/4, m.totals.maxChurn)
+}
+
+// renderBar renders a git diff --stat style bar of added and removed lines,
+// scaled so the file with the most changes fills the bar width
+func (m statsModel) renderBar(adds, dels int) string {
+	width := m.barWidth()
+	addLen, delLen := 0, 0
+	if m.totals.maxChurn > 0 {
+		addLen = scaleBar(adds, width, m.totals.maxChurn)
+		delLen = scaleBar(dels, width, m.totals.maxChurn)
+		// directory totals can be larger than any single file
+		if addLen+delLen > width {
+			addLen = width * adds / (adds + dels)
+			delLen = width - addLen
+		}
+	}
+
+	statBarStyle.Width(width + 1)
+	return statBarStyle.Render(
+		diffAddStyle.Render(strings.Repeat("+", addLen)) +
+			diffRemStyle.Render(strings.Repeat("-", delLen)),
+	)
+}
+
+// scaleBar scales count to width, rounding up so that any change is visible
+func scaleBar(count, width, total int) int {
+	if count == 0 {
+		return 0
+	}
+	return max((count*width+total-1)/total, 1)
+}
+
+func (m statsModel) renderTotals() string {
+	summary := fmt.Sprintf(
+		"%d files changed, %d insertions(+), %d deletions(-)",
+		m.totals.files,
+		m.totals.adds,
+		m.totals.dels,
+	)
+	if m.totals.binaries > 0 {
+		summary += fmt.Sprintf(", %d binary", m.totals.binaries)
+	}
+	return statTotalsStyle.Width(m.width).Render(summary)
+}
+
 func (m statsModel) render() string {
 	var lines []string
 	if m.end-m.start == 0 {
@@ -117,28 +524,82 @@ func (m statsModel) render() string {
 		for i := m.start; i < m.end; i++ {
 			lines = append(lines, m.renderStat(i))
 		}
+		for i := m.end - m.start; i < m.height; i++ {
+			lines = append(lines, "")
+		}
+		lines = append(lines, m.renderTotals())
 		return lipgloss.JoinVertical(lipgloss.Left, lines...)
 	}
 }
 
-func (m *statsModel) findNext(query string) {
-	q := strings.ToLower(query)
-	for i := m.cursor + 1; i < m.count; i++ {
-		c := strings.ToLower(m.renderStat(i))
-		if strings.Contains(c, q) {
-			m.setCursor(i)
-			break
+// matches returns the indexes of the rows whose paths match re
+func (m statsModel) matches(re *regexp.Regexp) []int {
+	var matches []int
+	for i, r := range m.rows {
+		if r.isDir() {
+			if re.MatchString(r.dir) {
+				matches = append(matches, i)
+			}
+			continue
+		}
+		s := m.stats[r.index]
+		if re.MatchString(s.Path) || (s.OldPath != "" && re.MatchString(s.OldPath)) {
+			matches = append(matches, i)
 		}
 	}
+	return matches
 }
 
-func (m *statsModel) findPrev(query string) {
-	q := strings.ToLower(query)
-	for i := m.cursor - 1; i >= 0; i++ {
-		c := strings.ToLower(m.renderStat(i))
-		if strings.Contains(c, q) {
-			m.setCursor(i)
-			break
+func (m *statsModel) findNext(re *regexp.Regexp) {
+	if i := nextMatch(m.matches(re), m.cursor); i >= 0 {
+		m.setCursor(i)
+	}
+}
+
+func (m *statsModel) findPrev(re *regexp.Regexp) {
+	if i := prevMatch(m.matches(re), m.cursor); i >= 0 {
+		m.setCursor(i)
+	}
+}
+
+// renderStatus renders a file status letter
+func renderStatus(status string) string {
+	switch status {
+	case "A":
+		statModStyle.Foreground(addFg)
+	case "D":
+		statModStyle.Foreground(remFg)
+	default:
+		statModStyle.Foreground(modFg)
+	}
+	return statModStyle.Render(status)
+}
+
+var modeNames = map[string]string{
+	"120000": "symlink",
+	"160000": "submodule",
+}
+
+// describeModeChange describes a change to a file's mode, or returns an empty
+// string if the mode didn't change
+func describeModeChange(s stat) string {
+	if s.Status == "A" {
+		if name, ok := modeNames[s.NewMode]; ok {
+			return name
 		}
+		return ""
 	}
-}
\ No newline at end of file
+	if s.Status == "D" || s.OldMode == s.NewMode {
+		return ""
+	}
+
+	oldMode := s.OldMode
+	if name, ok := modeNames[oldMode]; ok {
+		oldMode = name
+	}
+	newMode := s.NewMode
+	if name, ok := modeNames[newMode]; ok {
+		newMode = name
+	}
+	return oldMode + "→" + newMode
+}
@@ -71,4 +71,90 @@ var diffModStyle = lipgloss.NewStyle().
 	Foreground(modFg)
 var diffSepStyle = lipgloss.NewStyle().
 	Inline(true).
-	Foreground(lipgloss.Color("6"))
\ No newline at end of file
+	Foreground(lipgloss.Color("6"))
+var diffChangedStyle = lipgloss.NewStyle().
+	Inline(true).
+	Foreground(lipgloss.Color("3"))
+var activityTimeStyle = lipgloss.NewStyle().
+	Width(9).
+	PaddingRight(1).
+	Foreground(lipgloss.Color("4"))
+var activityOpStyle = lipgloss.NewStyle().
+	Width(7).
+	PaddingRight(1).
+	Foreground(lipgloss.Color("5"))
+var statBarStyle = lipgloss.NewStyle().
+	Inline(true).
+	PaddingRight(1)
+var statTotalsStyle = lipgloss.NewStyle().
+	Inline(true).
+	Foreground(lipgloss.Color("8"))
+var statReviewedStyle = lipgloss.NewStyle().
+	Width(2).
+	Foreground(addFg)
+var diffCommentStyle = lipgloss.NewStyle().
+	Inline(true).
+	Italic(true).
+	Foreground(lipgloss.Color("3"))
+var diffSelectedStyle = lipgloss.NewStyle().
+	Inline(true).
+	Background(cursorBg)
+var commentLocationStyle = lipgloss.NewStyle().
+	PaddingRight(2).
+	Foreground(lipgloss.Color("5"))
+var diffStickyStyle = lipgloss.NewStyle().
+	Inline(true).
+	Underline(true)
+var diffLineNumStyle = lipgloss.NewStyle().
+	Inline(true).
+	Align(lipgloss.Right).
+	Foreground(lipgloss.Color("8"))
+var optionLabelStyle = lipgloss.NewStyle().
+	Width(36).
+	PaddingLeft(1)
+var optionValueStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("6"))
+var diffMovedFromStyles = [2]lipgloss.Style{
+	lipgloss.NewStyle().Inline(true).Foreground(lipgloss.Color("5")),
+	lipgloss.NewStyle().Inline(true).Foreground(lipgloss.Color("13")),
+}
+var diffMovedToStyles = [2]lipgloss.Style{
+	lipgloss.NewStyle().Inline(true).Foreground(lipgloss.Color("4")),
+	lipgloss.NewStyle().Inline(true).Foreground(lipgloss.Color("12")),
+}
+var diffAddBg = lipgloss.Color("22")
+var diffRemBg = lipgloss.Color("52")
+var tokenStyles = map[tokenKind]lipgloss.Style{
+	tokPlain:           lipgloss.NewStyle().Inline(true),
+	tokKeyword:         lipgloss.NewStyle().Inline(true).Foreground(lipgloss.Color("5")),
+	tokString:          lipgloss.NewStyle().Inline(true).Foreground(lipgloss.Color("3")),
+	tokComment:         lipgloss.NewStyle().Inline(true).Foreground(lipgloss.Color("8")),
+	tokNumber:          lipgloss.NewStyle().Inline(true).Foreground(lipgloss.Color("6")),
+	tokKey:             lipgloss.NewStyle().Inline(true).Foreground(lipgloss.Color("4")),
+	tokVariable:        lipgloss.NewStyle().Inline(true).Foreground(lipgloss.Color("6")),
+	tokHeading:         lipgloss.NewStyle().Inline(true).Bold(true).Foreground(lipgloss.Color("4")),
+	tokCode:            lipgloss.NewStyle().Inline(true).Foreground(lipgloss.Color("3")),
+	tokEmphasis:        lipgloss.NewStyle().Inline(true).Italic(true),
+	tokWhitespace:      lipgloss.NewStyle().Inline(true).Foreground(lipgloss.Color("8")),
+	tokWhitespaceError: lipgloss.NewStyle().Inline(true).Background(lipgloss.Color("1")),
+	tokNote:            lipgloss.NewStyle().Inline(true).Italic(true).Foreground(lipgloss.Color("8")),
+	tokMatch:           lipgloss.NewStyle().Inline(true).Background(searchMatchBg).Foreground(searchMatchFg),
+}
+var diffWrapStyle = lipgloss.NewStyle().
+	Inline(true).
+	Foreground(lipgloss.Color("8"))
+var searchFileStyle = lipgloss.NewStyle().
+	Inline(true).
+	Bold(true).
+	Foreground(lipgloss.Color("4"))
+var searchMatchBg = lipgloss.Color("3")
+var searchMatchFg = lipgloss.Color("0")
+var fuzzyStyle = lipgloss.NewStyle().
+	Inline(true)
+var fuzzyMatchStyle = lipgloss.NewStyle().
+	Inline(true).
+	Bold(true).
+	Foreground(lipgloss.Color("3"))
+var fuzzyBorderStyle = lipgloss.NewStyle().
+	Inline(true).
+	Foreground(lipgloss.Color("8"))
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// statRow is a row in the stats view, which is either a file or, in tree
+// mode, a directory
+type statRow struct {
+	// index of the row's stat, or -1 for a directory
+	index int
+	// dir is the full path of a directory row
+	dir   string
+	label string
+	depth int
+	adds  int
+	dels  int
+}
+
+func (r statRow) isDir() bool {
+	return r.index < 0
+}
+
+type statNode struct {
+	name  string
+	path  string
+	dirs  map[string]*statNode
+	files []int
+	adds  int
+	dels  int
+}
+
+func newStatNode(name, path string) *statNode {
+	return &statNode{name: name, path: path, dirs: map[string]*statNode{}}
+}
+
+// flatStatRows returns one row for each stat in order
+func flatStatRows(stats []stat, order []int) (rows []statRow) {
+	for _, i := range order {
+		s := stats[i]
+		rows = append(rows, statRow{index: i, adds: s.Adds, dels: s.Dels})
+	}
+	return
+}
+
+// treeStatRows groups the stats in order by directory. Directories are listed
+// by name before files at each level, and chains of directories with no files
+// are shown as a single row. Files are listed in order within a directory.
+// The children of directories in collapsed are left out.
+func treeStatRows(stats []stat, order []int, collapsed map[string]bool) []statRow {
+	root := newStatNode("", "")
+
+	for _, i := range order {
+		s := stats[i]
+		node := root
+		node.adds += s.Adds
+		node.dels += s.Dels
+		parts := strings.Split(s.Path, "/")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node.dirs[part]
+			if !ok {
+				child = newStatNode(part, path.Join(node.path, part))
+				node.dirs[part] = child
+			}
+			node = child
+			node.adds += s.Adds
+			node.dels += s.Dels
+		}
+		node.files = append(node.files, i)
+	}
+
+	var rows []statRow
+	var walk func(node *statNode, depth int)
+	walk = func(node *statNode, depth int) {
+		var names []string
+		for name := range node.dirs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			dir := node.dirs[name]
+			label := dir.name
+			for len(dir.dirs) == 1 && len(dir.files) == 0 {
+				for _, child := range dir.dirs {
+					dir = child
+				}
+				label += "/" + dir.name
+			}
+
+			rows = append(rows, statRow{
+				index: -1,
+				dir:   dir.path,
+				label: label,
+				depth: depth,
+				adds:  dir.adds,
+				dels:  dir.dels,
+			})
+			if !collapsed[dir.path] {
+				walk(dir, depth+1)
+			}
+		}
+
+		for _, i := range node.files {
+			s := stats[i]
+			rows = append(rows, statRow{
+				index: i,
+				label: path.Base(s.Path),
+				depth: depth,
+				adds:  s.Adds,
+				dels:  s.Dels,
+			})
+		}
+	}
+	walk(root, 0)
+
+	return rows
+}
@@ -1,56 +1,118 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"io/fs"
 	"log"
 	"path/filepath"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
+// notifyFunc is called with an event type and a path relative to the top of
+// the worktree. For the "ready" event, the path is replaced by a short summary
+// of the watcher's startup.
 type notifyFunc func(string, string)
 
+type watchOptions struct {
+	// strategy is "walk" to watch every non-ignored directory in the
+	// worktree, or "tracked" to only watch directories known to git
+	strategy string
+	// pathspecs limits the tracked strategy to matching paths
+	pathspecs []string
+	// sparse limits the tracked strategy to the sparse-checkout cone, in
+	// cone mode
+	sparse bool
+}
+
 func getOpType(op fsnotify.Op) string {
 	if op&fsnotify.Write == fsnotify.Write {
 		return "modify"
 	}
-	if op&fsnotify.Create == fsnotify.Write {
+	if op&fsnotify.Create == fsnotify.Create {
 		return "add"
 	}
-	if op&fsnotify.Remove == fsnotify.Write {
+	if op&fsnotify.Remove == fsnotify.Remove {
 		return "remove"
 	}
+	if op&fsnotify.Rename == fsnotify.Rename {
+		return "rename"
+	}
 	return ""
 }
 
-func watchRepo(path string, notify notifyFunc) *fsnotify.Watcher {
+// walkDirs watches every directory under path that isn't ignored
+func walkDirs(path string, watcher *fsnotify.Watcher) (int, error) {
+	gitDir := filepath.Join(path, getGitDir())
+	count := 0
+
+	err := filepath.WalkDir(
+		path,
+		func(path string, d fs.DirEntry, err error) error {
+			if d.IsDir() {
+				if isIgnored(path) || path == gitDir {
+					return fs.SkipDir
+				}
+
+				count++
+				return watcher.Add(path)
+			}
+			return nil
+		},
+	)
+
+	return count, err
+}
+
+// trackedDirs watches the directories that git knows about
+func trackedDirs(path string, opts watchOptions, watcher *fsnotify.Watcher) (int, error) {
+	var cone []string
+	if opts.sparse {
+		cone = gitSparseCone()
+	}
+
+	count := 0
+	for _, dir := range gitTrackedDirs(opts.pathspecs, cone) {
+		if err := watcher.Add(filepath.Join(path, dir)); err != nil {
+			// a directory can be missing from disk, when it was deleted
+			// without staging or is outside of a sparse checkout
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func watchRepo(path string, opts watchOptions, notify notifyFunc) *fsnotify.Watcher {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	gitDir := filepath.Join(path, getGitDir())
+	prefix := getGitPrefix()
 
 	go func() {
-		err = filepath.WalkDir(
-			path,
-			func(path string, d fs.DirEntry, err error) error {
-				if d.IsDir() {
-					if isIgnored(path) || path == gitDir {
-						return fs.SkipDir
-					}
+		startTime := time.Now()
 
-					return watcher.Add(path)
-				}
-				return nil
-			},
-		)
+		var count int
+		if opts.strategy == "tracked" {
+			count, err = trackedDirs(path, opts, watcher)
+		} else {
+			count, err = walkDirs(path, watcher)
+		}
 
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		notify("ready", "")
+		elapsed := time.Since(startTime).Round(time.Millisecond)
+		notify("ready", fmt.Sprintf("watching %d dirs (ready in %s)", count, elapsed))
 
 		for {
 			select {
@@ -61,7 +123,7 @@ func watchRepo(path string, notify notifyFunc) *fsnotify.Watcher {
 				if !isIgnored(event.Name) {
 					opType := getOpType(event.Op)
 					if opType != "" {
-						notify(opType, event.Name)
+						notify(opType, filepath.Join(prefix, event.Name))
 					}
 				}
 			case err, ok := <-watcher.Errors:
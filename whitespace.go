@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// the tab width used when neither git nor .editorconfig sets one
+const defaultTabWidth = 8
+
+// tabWidths are the choices offered in the options menu, where 0 uses the
+// width configured for the file
+var tabWidths = []int{0, 2, 4, 8}
+
+// whitespaceRules are the whitespace errors git checks for, as set by
+// core.whitespace or the whitespace attribute
+type whitespaceRules struct {
+	blankAtEol       bool
+	spaceBeforeTab   bool
+	indentWithNonTab bool
+	tabInIndent      bool
+	crAtEol          bool
+	// tabWidth is 0 if it isn't set
+	tabWidth int
+}
+
+// parseWhitespaceRules applies a core.whitespace style list of rules, such as
+// "trailing-space,-space-before-tab,tabwidth=4", to the defaults
+func parseWhitespaceRules(value string) whitespaceRules {
+	r := whitespaceRules{blankAtEol: true, spaceBeforeTab: true}
+	for _, rule := range strings.Split(value, ",") {
+		rule = strings.TrimSpace(rule)
+		on := !strings.HasPrefix(rule, "-")
+		switch name := strings.TrimPrefix(rule, "-"); name {
+		case "trailing-space", "blank-at-eol":
+			r.blankAtEol = on
+		case "space-before-tab":
+			r.spaceBeforeTab = on
+		case "indent-with-non-tab":
+			r.indentWithNonTab = on
+		case "tab-in-indent":
+			r.tabInIndent = on
+		case "cr-at-eol":
+			r.crAtEol = on
+		default:
+			if strings.HasPrefix(name, "tabwidth=") {
+				r.tabWidth, _ = strconv.Atoi(strings.TrimPrefix(name, "tabwidth="))
+			}
+		}
+	}
+	return r
+}
+
+// gitWhitespaceRules returns the whitespace rules for a file from its
+// whitespace attribute, falling back to core.whitespace
+func gitWhitespaceRules(path string) whitespaceRules {
+	out, err := exec.Command("git", "check-attr", "whitespace", "--", path).Output()
+	value := ""
+	if err == nil {
+		parts := strings.SplitN(strings.TrimSpace(string(out)), ": ", 3)
+		if len(parts) == 3 {
+			value = parts[2]
+		}
+	}
+
+	switch value {
+	case "set":
+		return whitespaceRules{
+			blankAtEol:       true,
+			spaceBeforeTab:   true,
+			indentWithNonTab: true,
+		}
+	case "unset":
+		return whitespaceRules{}
+	case "", "unspecified":
+		// git config exits with an error if the key isn't set
+		core, _ := exec.Command("git", "config", "core.whitespace").Output()
+		return parseWhitespaceRules(strings.TrimSpace(string(core)))
+	}
+	return parseWhitespaceRules(value)
+}
+
+// editorconfigGlob converts an .editorconfig section glob to a regexp that
+// matches paths relative to the directory of the .editorconfig file
+func editorconfigGlob(glob string) *regexp.Regexp {
+	anchored := strings.Contains(glob, "/")
+	glob = strings.TrimPrefix(glob, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '{':
+			b.WriteString("(")
+		case '}':
+			b.WriteString(")")
+		case ',':
+			b.WriteString("|")
+		case '[', ']':
+			b.WriteByte(c)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// editorconfigTabWidth returns the tab width .editorconfig files set for a
+// path relative to the top of the worktree, or 0
+func editorconfigTabWidth(path string) int {
+	top := getGitTopLevel()
+	abs := filepath.Join(top, path)
+
+	// .editorconfig files closer to the file take precedence, so they're
+	// read last
+	var dirs []string
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+		if isEditorconfigRoot(dir) || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	width := 0
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil {
+			continue
+		}
+		props := readEditorconfig(filepath.Join(dir, ".editorconfig"), filepath.ToSlash(rel))
+		if w, err := strconv.Atoi(props["tab_width"]); err == nil {
+			width = w
+		} else if w, err := strconv.Atoi(props["indent_size"]); err == nil {
+			width = w
+		}
+	}
+	return width
+}
+
+func isEditorconfigRoot(dir string) bool {
+	return readEditorconfig(filepath.Join(dir, ".editorconfig"), "")["root"] == "true"
+}
+
+// readEditorconfig returns the properties an .editorconfig file sets for a
+// path. Properties before the first section, such as root, are always
+// returned.
+func readEditorconfig(file, path string) map[string]string {
+	props := map[string]string{}
+	f, err := os.Open(file)
+	if err != nil {
+		return props
+	}
+	defer f.Close()
+
+	matches := true
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			re := editorconfigGlob(line[1 : len(line)-1])
+			matches = path != "" && re != nil && re.MatchString(path)
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok && matches {
+			props[strings.ToLower(strings.TrimSpace(key))] = strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+	return props
+}
+
+// fileTabWidth returns the tab width for a file: the tabwidth set in git's
+// whitespace rules, then .editorconfig, then defaultTabWidth
+func fileTabWidth(path string, rules whitespaceRules) int {
+	if rules.tabWidth > 0 {
+		return rules.tabWidth
+	}
+	if width := editorconfigTabWidth(path); width > 0 {
+		return width
+	}
+	return defaultTabWidth
+}
+
+// whitespaceErrors marks the bytes of an added line that break the
+// whitespace rules
+func whitespaceErrors(line string, r whitespaceRules, tabWidth int) []bool {
+	errs := make([]bool, len(line))
+	found := false
+	mark := func(from, to int) {
+		for i := from; i < to; i++ {
+			errs[i] = true
+			found = true
+		}
+	}
+
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+
+	if r.spaceBeforeTab {
+		if tab := strings.LastIndexByte(line[:indent], '\t'); tab >= 0 {
+			for i := tab - 1; i >= 0; i-- {
+				if line[i] == ' ' {
+					errs[i] = true
+					found = true
+				}
+			}
+		}
+	}
+	if r.tabInIndent {
+		for i := 0; i < indent; i++ {
+			if line[i] == '\t' {
+				mark(i, i+1)
+			}
+		}
+	}
+	if r.indentWithNonTab && tabWidth > 0 {
+		if spaces := strings.Index(line[:indent], strings.Repeat(" ", tabWidth)); spaces >= 0 {
+			mark(spaces, indent)
+		}
+	}
+	if r.blankAtEol {
+		trimmed := strings.TrimRight(line, " \t\r")
+		end := len(line)
+		if r.crAtEol && strings.HasSuffix(line, "\r") {
+			trimmed = strings.TrimRight(line[:len(line)-1], " \t")
+			end--
+		}
+		mark(len(trimmed), end)
+	}
+
+	if !found {
+		return nil
+	}
+	return errs
+}
+
+// layoutSpans expands the tabs of a line to tab stops, marks whitespace
+// errors, and replaces tabs, trailing spaces and carriage returns with
+// visible glyphs if visible is true
+func layoutSpans(spans []span, errs []bool, tabWidth int, visible bool) []span {
+	line := ""
+	for _, s := range spans {
+		line += s.text
+	}
+	trailing := len(strings.TrimRight(line, " \t\r"))
+
+	var b spanBuilder
+	col, pos := 0, 0
+	for _, s := range spans {
+		for i := 0; i < len(s.text); {
+			c := s.text[i]
+			kind := s.kind
+			text := ""
+			size := 1
+
+			switch c {
+			case '\t':
+				width := tabWidth - col%tabWidth
+				if visible {
+					text = "→" + strings.Repeat(" ", width-1)
+					kind = tokWhitespace
+				} else {
+					text = strings.Repeat(" ", width)
+				}
+				col += width
+			case '\r':
+				if visible {
+					text = "␍"
+					kind = tokWhitespace
+					col++
+				}
+			case ' ':
+				text = " "
+				if visible && pos >= trailing {
+					text = "·"
+					kind = tokWhitespace
+				}
+				col++
+			default:
+				_, size = utf8.DecodeRuneInString(s.text[i:])
+				text = s.text[i : i+size]
+				col++
+			}
+
+			if errs != nil && errs[pos] {
+				kind = tokWhitespaceError
+			}
+			b.add(text, kind)
+			i += size
+			pos += size
+		}
+	}
+	return b
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// the number of columns h and l scroll the diff view by
+const hscrollStep = 4
+
+// styledLine is a diff line split into its prefix, which stays in place when
+// scrolling horizontally, and its content
+type styledLine struct {
+	prefix      string
+	prefixStyle lipgloss.Style
+	spans       []span
+	// base is the style of plain spans, and bg the background of all spans
+	base lipgloss.Style
+	bg   lipgloss.TerminalColor
+}
+
+func spansWidth(spans []span) int {
+	width := 0
+	for _, s := range spans {
+		width += utf8.RuneCountInString(s.text)
+	}
+	return width
+}
+
+// sliceSpans returns the part of spans that's width columns wide, starting at
+// column from
+func sliceSpans(spans []span, from, width int) []span {
+	var b spanBuilder
+	col := 0
+	for _, s := range spans {
+		start := -1
+		for i := 0; i < len(s.text); col++ {
+			if col == from+width {
+				break
+			}
+			if col == from || (start < 0 && col > from) {
+				start = i
+			}
+			_, size := utf8.DecodeRuneInString(s.text[i:])
+			i += size
+			if start >= 0 && (i == len(s.text) || col+1 == from+width) {
+				b.add(s.text[start:i], s.kind)
+			}
+		}
+		if col >= from+width {
+			break
+		}
+	}
+	return b
+}
+
+// contentWidth returns the number of columns available for the content of a
+// line, after the gutter and prefix. A column is kept for the change marker
+// so wrapped lines don't move when changes are marked.
+func (m diffModel) contentWidth() int {
+	width := m.width - 2
+	if m.showLineNums {
+		width -= 2*(len(strconv.Itoa(m.maxLineNum))+1) + 1
+	}
+	return max(width, 1)
+}
+
+// lineParts returns the number of screen rows a diff line takes
+func (m diffModel) lineParts(index int) int {
+	if !m.wrap {
+		return 1
+	}
+	width := m.contentWidth()
+	return max((spansWidth(m.styleLine(index).spans)+width-1)/width, 1)
+}
+
+// renderLinePart renders a screen row of a diff line: the part of the line
+// scrolled into view, or one part of a wrapped line
+func (m diffModel) renderLinePart(index, part int, selected bool) string {
+	l := m.styleLine(index)
+	if selected {
+		l.prefixStyle = diffSelectedStyle
+		l.base = diffSelectedStyle
+		l.bg = cursorBg
+	}
+
+	width := m.contentWidth()
+	from := m.hscroll
+	if m.wrap {
+		from = part * width
+	}
+	spans := sliceSpans(l.spans, from, width)
+
+	if part > 0 {
+		return m.renderGutter(-1) + diffWrapStyle.Render("↪") + renderSpans(spans, l.base, l.bg)
+	}
+	return m.renderGutter(index) + l.prefixStyle.Render(l.prefix) + renderSpans(spans, l.base, l.bg)
+}
+
+// relayout rebuilds the rows after a change to their width, keeping the line
+// at the top of the view in place
+func (m *diffModel) relayout() {
+	top := m.topLine()
+	m.buildRows()
+	if top >= 0 {
+		m.scrollToLine(top)
+	}
+}
+
+func (m *diffModel) setSize(width, height int) {
+	resized := width != m.width
+	m.setWidth(width)
+	// a row is kept for the sticky hunk header, so the line at the top of
+	// the list is always on screen
+	m.setHeight(height - 1)
+	if resized && m.wrap {
+		m.relayout()
+	}
+}
+
+// toggleWrap switches between soft-wrapping long lines and scrolling them
+// horizontally
+func (m *diffModel) toggleWrap() {
+	m.wrap = !m.wrap
+	m.hscroll = 0
+	m.relayout()
+}
+
+// toggleLineNums shows or hides the line number gutter
+func (m *diffModel) toggleLineNums() {
+	m.showLineNums = !m.showLineNums
+	if m.wrap {
+		m.relayout()
+	}
+}
+
+// toggleWhitespace shows or hides whitespace glyphs
+func (m *diffModel) toggleWhitespace() {
+	m.opts.showWhitespace = !m.opts.showWhitespace
+	if m.wrap {
+		m.relayout()
+	}
+}
+
+// scrollRight scrolls long lines by delta columns, up to the end of the
+// longest line
+func (m *diffModel) scrollRight(delta int) {
+	if m.wrap {
+		return
+	}
+	longest := 0
+	for i := range m.diff {
+		longest = max(longest, spansWidth(m.styleLine(i).spans))
+	}
+	m.hscroll = max(min(m.hscroll+delta, longest-m.contentWidth()), 0)
+}
+
+// getScrollStr describes the horizontal scroll position or wrap mode
+func (m diffModel) getScrollStr() string {
+	if m.wrap {
+		return "Wr"
+	}
+	if m.hscroll > 0 {
+		return fmt.Sprintf(">%d", m.hscroll)
+	}
+	return ""
+}